@@ -136,8 +136,51 @@ func initLogger(cfg config.LoggingConfig) (*zap.Logger, error) {
 	return zapCfg.Build()
 }
 
-// initLLMClient initializes the LLM client based on configuration.
+// initLLMClient initializes the LLM client, including its fallback chain, based on configuration.
 func initLLMClient(cfg config.LLMConfig, logger *zap.Logger) (*llm.Client, error) {
+	cassette, err := llm.OpenCassette(cfg.Cassette, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LLM cassette: %w", err)
+	}
+
+	llmClient, err := newBackendClient(cfg, cassette, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	client := llm.NewClient(llmClient, &cfg, logger)
+
+	for i, fb := range cfg.Fallbacks {
+		fbClient, err := newBackendClient(fb.Resolve(cfg), cassette, logger)
+		if err != nil {
+			return nil, fmt.Errorf("fallback %d: %w", i+1, err)
+		}
+		client.AddFallback(fb.Provider, fb.Model, fbClient)
+	}
+
+	return client, nil
+}
+
+// newBackendClient creates the LLM client of one backend, wrapped by the
+// cassette when recording or replaying. Replay needs no provider at all.
+func newBackendClient(cfg config.LLMConfig, cassette *llm.Cassette, logger *zap.Logger) (ports.LLMClient, error) {
+	if cassette != nil && cassette.Mode() == llm.CassetteReplay {
+		return cassette.Wrap(nil), nil
+	}
+
+	llmClient, err := newProviderClient(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cassette != nil {
+		return cassette.Wrap(llmClient), nil
+	}
+	return llmClient, nil
+}
+
+// newProviderClient creates the provider-specific LLM client.
+func newProviderClient(cfg config.LLMConfig, logger *zap.Logger) (ports.LLMClient, error) {
 	var llmClient ports.LLMClient
 	var err error
 
@@ -149,12 +192,42 @@ func initLLMClient(cfg config.LLMConfig, logger *zap.Logger) (*llm.Client, error
 		}
 
 	case "openai":
-		// TODO: Implement OpenAI client when available
-		return nil, fmt.Errorf("OpenAI provider not yet implemented")
+		llmClient, err = llm.NewOpenAIClient(openAIConfig(cfg), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OpenAI client: %w", err)
+		}
+
+	case "local":
+		llmClient, err = llm.NewLocalClient(openAIConfig(cfg), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create local LLM client: %w", err)
+		}
+
+	case "fake":
+		llmClient, err = llm.NewFakeClient(llm.FakeConfig{
+			ScriptPath: cfg.FakeScript,
+			Model:      cfg.Model,
+			Timeout:    cfg.MaxTimeout(),
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create fake LLM client: %w", err)
+		}
 
 	default:
 		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.Provider)
 	}
 
-	return llm.NewClient(llmClient, &cfg, logger), nil
+	return llmClient, nil
+}
+
+// openAIConfig maps the LLM configuration onto the OpenAI-compatible client settings.
+func openAIConfig(cfg config.LLMConfig) llm.OpenAIConfig {
+	return llm.OpenAIConfig{
+		BaseURL:          cfg.BaseURL,
+		APIKey:           cfg.APIKey,
+		AuthHeader:       cfg.AuthHeader,
+		Model:            cfg.Model,
+		Timeout:          cfg.MaxTimeout(),
+		StructuredOutput: cfg.StructuredOutput,
+	}
 }
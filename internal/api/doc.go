@@ -5,10 +5,11 @@
 // Endpoints:
 //
 //   GET /health - Health check endpoint
-//   GET /ready  - Readiness check endpoint
+//   GET /ready  - Readiness check endpoint (503 while every LLM circuit is open)
 //
 //   POST /api/v1/plan     - Generate a graph from a task
 //   POST /api/v1/validate - Validate a graph JSON
+//   GET  /api/v1/stats    - Aggregate LLM usage and estimated cost
 //
 // Example plan request:
 //
@@ -20,7 +21,9 @@
 //	  },
 //	  "constraints": {
 //	    "max_nodes": 10,
-//	    "preferred_modes": ["agent", "llm"]
+//	    "preferred_modes": ["agent", "llm"],
+//	    "max_tokens_budget": 20000,
+//	    "max_cost": 0.10
 //	  }
 //	}
 //
@@ -36,12 +39,30 @@
 //	    "llm_provider": "anthropic",
 //	    "llm_model": "claude-3-5-sonnet-20241022",
 //	    "tokens_used": 1234,
+//	    "input_tokens": 1034,
+//	    "output_tokens": 200,
+//	    "llm_calls": 2,
+//	    "estimated_cost": 0.0061,
+//	    "phase_usage": [ ... ],
 //	    "duration": "2.5s",
 //	    "success": true
 //	  },
 //	  "created_at": "2024-01-01T12:00:00Z"
 //	}
 //
+// If the next LLM call would exceed max_tokens_budget or max_cost, planning
+// stops with 422 Unprocessable Entity; the body carries the partial plan,
+// including the best graph produced so far:
+//
+//	{
+//	  "error": "planning budget exhausted",
+//	  "details": "...",
+//	  "plan": { "graph": { ... }, "metadata": { "success": false, ... } }
+//	}
+//
+// While the circuit breaker of every LLM backend is open, /api/v1/plan
+// fails fast with 503 Service Unavailable and a Retry-After header.
+//
 // Example validate request:
 //
 //	POST /api/v1/validate
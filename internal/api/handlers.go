@@ -1,8 +1,13 @@
 package api
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/internal/planner"
 	"github.com/aescanero/dago-node-planner/pkg/models"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -30,6 +35,41 @@ func (s *Server) planHandler(c *gin.Context) {
 
 	// Execute planning
 	resp, err := s.planner.Plan(c.Request.Context(), &req)
+	if errors.Is(err, llm.ErrCircuitOpen) {
+		s.logger.Warn("planning rejected, LLM circuit breaker is open",
+			zap.Error(err),
+		)
+		if wait := s.planner.LLMRetryAfter(); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "LLM provider unavailable",
+			"details": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, llm.ErrContextWindowExceeded) {
+		s.logger.Warn("planning rejected, request too large for the model",
+			zap.Error(err),
+		)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "request exceeds the LLM context window",
+			"details": err.Error(),
+		})
+		return
+	}
+	var budgetErr *planner.BudgetExhaustedError
+	if errors.As(err, &budgetErr) {
+		s.logger.Warn("planning stopped, budget exhausted",
+			zap.Error(err),
+		)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "planning budget exhausted",
+			"details": err.Error(),
+			"plan":    budgetErr.Plan,
+		})
+		return
+	}
 	if err != nil {
 		s.logger.Error("planning failed",
 			zap.Error(err),
@@ -70,19 +110,17 @@ func (s *Server) validateHandler(c *gin.Context) {
 	s.logger.Debug("received validate request")
 
 	// Validate graph
-	err := s.planner.ValidateGraph(req.GraphJSON)
-	if err != nil {
+	result := s.planner.ValidateGraph(req.GraphJSON)
+	if !result.Valid {
 		s.logger.Debug("validation failed",
-			zap.Error(err),
+			zap.Strings("errors", result.Errors),
 		)
-		c.JSON(http.StatusOK, models.ValidationResult{
-			Valid:  false,
-			Errors: []string{err.Error()},
-		})
-		return
 	}
 
-	c.JSON(http.StatusOK, models.ValidationResult{
-		Valid: true,
-	})
+	c.JSON(http.StatusOK, result)
+}
+
+// statsHandler handles GET /api/v1/stats requests.
+func (s *Server) statsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.planner.Stats())
 }
@@ -59,6 +59,9 @@ func (s *Server) registerRoutes() {
 		// Planning endpoints
 		v1.POST("/plan", s.planHandler)
 		v1.POST("/validate", s.validateHandler)
+
+		// Usage statistics
+		v1.GET("/stats", s.statsHandler)
 	}
 }
 
@@ -104,10 +107,20 @@ func (s *Server) healthHandler(c *gin.Context) {
 }
 
 // readyHandler handles readiness check requests.
+// The service is not ready while the circuit of every LLM backend is open.
 func (s *Server) readyHandler(c *gin.Context) {
-	// TODO: Check dependencies (LLM client, schema repo, etc.)
+	if !s.planner.LLMAvailable() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "not ready",
+			"llm":    s.planner.LLMStatus(),
+			"time":   time.Now().UTC(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "ready",
+		"llm":    s.planner.LLMStatus(),
 		"time":   time.Now().UTC(),
 	})
 }
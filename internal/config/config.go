@@ -27,13 +27,176 @@ type ServerConfig struct {
 
 // LLMConfig contains LLM provider configuration.
 type LLMConfig struct {
-	Provider    string        `yaml:"provider"` // anthropic, openai
-	APIKey      string        `yaml:"api_key"`
+	Provider    string        `yaml:"provider"`    // anthropic, openai, local, fake
+	APIKey      string        `yaml:"api_key"`     // optional for the local and fake providers
+	BaseURL     string        `yaml:"base_url"`    // optional API root for openai-compatible endpoints
+	AuthHeader  string        `yaml:"auth_header"` // header carrying the API key (default: Authorization)
+	FakeScript  string        `yaml:"fake_script"` // response script for the fake provider
 	Model       string        `yaml:"model"`
 	MaxTokens   int           `yaml:"max_tokens"`
 	Temperature float64       `yaml:"temperature"`
 	Timeout     time.Duration `yaml:"timeout"`
 	RetryConfig RetryConfig   `yaml:"retry"`
+
+	// Profiles override the model and generation parameters per planning
+	// phase (analysis, generation, repair, critic)
+	Profiles map[string]PhaseProfile `yaml:"profiles"`
+
+	// Fallbacks are tried in order when the primary provider fails
+	Fallbacks []FallbackConfig `yaml:"fallbacks"`
+
+	// CircuitBreaker guards each provider against repeated failures
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+
+	// RateLimit caps the requests and tokens sent to each provider
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	// Pricing maps model names to their token prices for cost estimation
+	Pricing map[string]ModelPricing `yaml:"pricing"`
+
+	// Capabilities maps model names to their token limits, extending the built-in table
+	Capabilities map[string]ModelCapabilities `yaml:"capabilities"`
+
+	// Cassette records provider calls to a file or replays them from it
+	Cassette CassetteConfig `yaml:"cassette"`
+
+	// StructuredOutput uses provider-native JSON schema output where supported
+	StructuredOutput bool `yaml:"structured_output"`
+}
+
+// PhaseProfile contains the model and generation parameters of a planning
+// phase. Unset fields are inherited from the profile of the parent phase,
+// if any, then from the top-level LLM settings.
+type PhaseProfile struct {
+	Model       string        `yaml:"model"` // model of the primary provider; fallbacks keep their own
+	MaxTokens   int           `yaml:"max_tokens"`
+	Temperature *float64      `yaml:"temperature"`
+	Timeout     time.Duration `yaml:"timeout"` // per LLM call attempt
+}
+
+// profileParents lists the phases that accept a profile, each mapped to the
+// phase it inherits unset fields from ("" for none).
+var profileParents = map[string]string{
+	"analysis":   "",
+	"generation": "",
+	"repair":     "generation",
+	"critic":     "",
+}
+
+// Profile returns the profile of phase with every field resolved.
+func (c *LLMConfig) Profile(phase string) PhaseProfile {
+	var p PhaseProfile
+	for name := phase; name != ""; name = profileParents[name] {
+		parent := c.Profiles[name]
+		if p.Model == "" {
+			p.Model = parent.Model
+		}
+		if p.MaxTokens == 0 {
+			p.MaxTokens = parent.MaxTokens
+		}
+		if p.Temperature == nil {
+			p.Temperature = parent.Temperature
+		}
+		if p.Timeout == 0 {
+			p.Timeout = parent.Timeout
+		}
+	}
+
+	if p.Model == "" {
+		p.Model = c.Model
+	}
+	if p.MaxTokens == 0 {
+		p.MaxTokens = c.MaxTokens
+	}
+	if p.Temperature == nil {
+		temperature := c.Temperature
+		p.Temperature = &temperature
+	}
+	if p.Timeout == 0 {
+		p.Timeout = c.Timeout
+	}
+
+	return p
+}
+
+// MaxTimeout returns the longest timeout of any phase, which bounds the
+// HTTP requests of the provider clients.
+func (c *LLMConfig) MaxTimeout() time.Duration {
+	timeout := c.Timeout
+	for _, p := range c.Profiles {
+		timeout = max(timeout, p.Timeout)
+	}
+	return timeout
+}
+
+// CassetteConfig contains LLM record/replay configuration.
+type CassetteConfig struct {
+	Mode string `yaml:"mode"` // off, record, replay
+	Path string `yaml:"path"` // cassette file
+}
+
+// ModelPricing contains the token prices of a model, in USD per million tokens.
+type ModelPricing struct {
+	InputPerMillion  float64 `yaml:"input_per_million"`
+	OutputPerMillion float64 `yaml:"output_per_million"`
+}
+
+// ModelCapabilities contains the token limits of a model.
+type ModelCapabilities struct {
+	ContextWindow   int `yaml:"context_window"`    // tokens of prompt and output combined
+	MaxOutputTokens int `yaml:"max_output_tokens"` // tokens the model can generate per call
+}
+
+// CircuitBreakerConfig contains circuit breaker configuration for LLM providers.
+type CircuitBreakerConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	FailureRatio     float64       `yaml:"failure_ratio"`      // failure ratio that opens the circuit (0.0-1.0)
+	MinRequests      int           `yaml:"min_requests"`       // requests in the window before the ratio is evaluated
+	Window           time.Duration `yaml:"window"`             // rolling window for counting failures
+	CoolDown         time.Duration `yaml:"cool_down"`          // time the circuit stays open before probing
+	HalfOpenRequests int           `yaml:"half_open_requests"` // probe requests allowed while half-open
+}
+
+// RateLimitConfig contains client-side rate limits for LLM providers.
+type RateLimitConfig struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"` // 0 disables the request limit
+	TokensPerMinute   int `yaml:"tokens_per_minute"`   // estimated prompt plus max output tokens; 0 disables the token limit
+}
+
+// FallbackConfig describes one provider/model entry of the fallback chain.
+type FallbackConfig struct {
+	Provider   string `yaml:"provider"`
+	APIKey     string `yaml:"api_key"`
+	BaseURL    string `yaml:"base_url"`
+	AuthHeader string `yaml:"auth_header"`
+	Model      string `yaml:"model"`
+}
+
+// Resolve returns the LLM configuration for this fallback entry. Connection
+// settings left empty are inherited from the primary configuration when both
+// use the same provider.
+func (f FallbackConfig) Resolve(primary LLMConfig) LLMConfig {
+	resolved := primary
+	resolved.Provider = f.Provider
+	resolved.Model = f.Model
+	resolved.Fallbacks = nil
+
+	if f.Provider != primary.Provider {
+		resolved.APIKey = ""
+		resolved.BaseURL = ""
+		resolved.AuthHeader = ""
+	}
+	if f.APIKey != "" {
+		resolved.APIKey = f.APIKey
+	}
+	if f.BaseURL != "" {
+		resolved.BaseURL = f.BaseURL
+	}
+	if f.AuthHeader != "" {
+		resolved.AuthHeader = f.AuthHeader
+	}
+
+	return resolved
 }
 
 // RetryConfig contains retry configuration for LLM calls.
@@ -42,6 +205,7 @@ type RetryConfig struct {
 	InitialDelay time.Duration `yaml:"initial_delay"`
 	MaxDelay     time.Duration `yaml:"max_delay"`
 	Multiplier   float64       `yaml:"multiplier"`
+	Jitter       float64       `yaml:"jitter"` // fraction of the delay randomised on each retry (0.0-1.0)
 }
 
 // PlanningConfig contains planning-specific configuration.
@@ -51,7 +215,54 @@ type PlanningConfig struct {
 	PromptPath          string  `yaml:"prompt_path"`
 	EnableValidation    bool    `yaml:"enable_validation"`
 	EnableAnalysis      bool    `yaml:"enable_analysis"`
+	EnableLint          bool    `yaml:"enable_lint"` // semantic checks of schema-valid graphs
 	ConfidenceThreshold float64 `yaml:"confidence_threshold"`
+	RepairHistory       int     `yaml:"repair_history"`     // failed attempts kept in repair conversations (0: single-message repair prompts)
+	MaxContinuations    int     `yaml:"max_continuations"`  // continuation requests per truncated response (0: disabled)
+	Samples             int     `yaml:"samples"`            // candidate graphs generated per plan (1: no sampling)
+	SampleTemperature   float64 `yaml:"sample_temperature"` // temperature of candidate generation when sampling
+
+	// Routes map task complexity (simple, moderate, complex) to the model
+	// and limits used to generate the graph
+	Routes map[string]ComplexityRoute `yaml:"routes"`
+
+	// Strategies adjust refinement iterations after failed ones
+	Strategies StrategiesConfig `yaml:"strategies"`
+}
+
+// ComplexityRoute contains the model and limits of graph generation for
+// tasks of one complexity level. Unset fields keep the defaults.
+type ComplexityRoute struct {
+	Model           string `yaml:"model"`             // model of generation and repair calls on the primary provider
+	MaxIterations   int    `yaml:"max_iterations"`    // refinement iteration budget
+	MaxTokens       int    `yaml:"max_tokens"`        // output tokens per generation and repair call
+	MaxTokensBudget int    `yaml:"max_tokens_budget"` // total tokens of the plan
+}
+
+// StrategiesConfig contains the iteration strategies of the refinement loop.
+// A strategy is disabled while its threshold is zero.
+type StrategiesConfig struct {
+	Escalate    EscalateStrategyConfig    `yaml:"escalate"`
+	Restart     RestartStrategyConfig     `yaml:"restart"`
+	Temperature TemperatureStrategyConfig `yaml:"temperature"`
+}
+
+// EscalateStrategyConfig switches to a stronger model after failed repairs.
+type EscalateStrategyConfig struct {
+	After int    `yaml:"after"` // failed repairs before escalating
+	Model string `yaml:"model"` // model of the escalated iterations on the primary provider
+}
+
+// RestartStrategyConfig restarts from the planning prompt after failed repairs.
+type RestartStrategyConfig struct {
+	After int `yaml:"after"` // consecutive failed repairs before restarting
+}
+
+// TemperatureStrategyConfig raises the temperature on repeated identical failures.
+type TemperatureStrategyConfig struct {
+	Repeats int     `yaml:"repeats"` // identical failures in a row before raising
+	Step    float64 `yaml:"step"`    // temperature added each time
+	Max     float64 `yaml:"max"`     // highest temperature reached
 }
 
 // LoggingConfig contains logging configuration.
@@ -82,7 +293,17 @@ func Load(configPath string) (*Config, error) {
 				InitialDelay: 1 * time.Second,
 				MaxDelay:     10 * time.Second,
 				Multiplier:   2.0,
+				Jitter:       0.2,
+			},
+			CircuitBreaker: CircuitBreakerConfig{
+				Enabled:          true,
+				FailureRatio:     0.5,
+				MinRequests:      5,
+				Window:           60 * time.Second,
+				CoolDown:         30 * time.Second,
+				HalfOpenRequests: 1,
 			},
+			StructuredOutput: true,
 		},
 		Planning: PlanningConfig{
 			MaxIterations:       3,
@@ -90,7 +311,15 @@ func Load(configPath string) (*Config, error) {
 			PromptPath:          "./prompts",
 			EnableValidation:    true,
 			EnableAnalysis:      true,
+			EnableLint:          true,
 			ConfidenceThreshold: 0.8,
+			RepairHistory:       2,
+			MaxContinuations:    2,
+			Samples:             1,
+			SampleTemperature:   0.7,
+			Strategies: StrategiesConfig{
+				Temperature: TemperatureStrategyConfig{Step: 0.2, Max: 1.0},
+			},
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
@@ -111,6 +340,9 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
+	// Fill the defaults YAML decoding replaced as a whole
+	cfg.applyDefaults()
+
 	// Override with environment variables
 	cfg.applyEnvVars()
 
@@ -122,6 +354,37 @@ func Load(configPath string) (*Config, error) {
 	return cfg, nil
 }
 
+// defaultProfiles are the phase profile fields applied where the
+// configuration leaves them unset.
+var defaultProfiles = map[string]PhaseProfile{
+	"analysis": {MaxTokens: 1024},
+}
+
+// applyDefaults fills unset fields of map entries, which YAML decoding
+// replaces as a whole instead of merging with the defaults.
+func (c *Config) applyDefaults() {
+	for phase, def := range defaultProfiles {
+		p := c.LLM.Profiles[phase]
+		if p.Model == "" {
+			p.Model = def.Model
+		}
+		if p.MaxTokens == 0 {
+			p.MaxTokens = def.MaxTokens
+		}
+		if p.Temperature == nil {
+			p.Temperature = def.Temperature
+		}
+		if p.Timeout == 0 {
+			p.Timeout = def.Timeout
+		}
+
+		if c.LLM.Profiles == nil {
+			c.LLM.Profiles = make(map[string]PhaseProfile)
+		}
+		c.LLM.Profiles[phase] = p
+	}
+}
+
 // applyEnvVars overrides configuration with environment variables.
 func (c *Config) applyEnvVars() {
 	if v := os.Getenv("PLANNER_SERVER_HOST"); v != "" {
@@ -137,6 +400,15 @@ func (c *Config) applyEnvVars() {
 	if v := os.Getenv("PLANNER_LLM_API_KEY"); v != "" {
 		c.LLM.APIKey = v
 	}
+	if v := os.Getenv("PLANNER_LLM_BASE_URL"); v != "" {
+		c.LLM.BaseURL = v
+	}
+	if v := os.Getenv("PLANNER_LLM_AUTH_HEADER"); v != "" {
+		c.LLM.AuthHeader = v
+	}
+	if v := os.Getenv("PLANNER_LLM_FAKE_SCRIPT"); v != "" {
+		c.LLM.FakeScript = v
+	}
 	if v := os.Getenv("PLANNER_LLM_MODEL"); v != "" {
 		c.LLM.Model = v
 	}
@@ -146,6 +418,12 @@ func (c *Config) applyEnvVars() {
 	if v := os.Getenv("PLANNER_LLM_TEMPERATURE"); v != "" {
 		_, _ = fmt.Sscanf(v, "%f", &c.LLM.Temperature)
 	}
+	if v := os.Getenv("PLANNER_LLM_CASSETTE_MODE"); v != "" {
+		c.LLM.Cassette.Mode = v
+	}
+	if v := os.Getenv("PLANNER_LLM_CASSETTE_PATH"); v != "" {
+		c.LLM.Cassette.Path = v
+	}
 
 	if v := os.Getenv("PLANNER_MAX_ITERATIONS"); v != "" {
 		_, _ = fmt.Sscanf(v, "%d", &c.Planning.MaxIterations)
@@ -156,6 +434,18 @@ func (c *Config) applyEnvVars() {
 	if v := os.Getenv("PLANNER_PROMPT_PATH"); v != "" {
 		c.Planning.PromptPath = v
 	}
+	if v := os.Getenv("PLANNER_REPAIR_HISTORY"); v != "" {
+		_, _ = fmt.Sscanf(v, "%d", &c.Planning.RepairHistory)
+	}
+	if v := os.Getenv("PLANNER_MAX_CONTINUATIONS"); v != "" {
+		_, _ = fmt.Sscanf(v, "%d", &c.Planning.MaxContinuations)
+	}
+	if v := os.Getenv("PLANNER_SAMPLES"); v != "" {
+		_, _ = fmt.Sscanf(v, "%d", &c.Planning.Samples)
+	}
+	if v := os.Getenv("PLANNER_SAMPLE_TEMPERATURE"); v != "" {
+		_, _ = fmt.Sscanf(v, "%f", &c.Planning.SampleTemperature)
+	}
 
 	if v := os.Getenv("PLANNER_LOG_LEVEL"); v != "" {
 		c.Logging.Level = v
@@ -171,15 +461,88 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
 
+	switch c.LLM.Cassette.Mode {
+	case "", "off":
+	case "record", "replay":
+		if c.LLM.Cassette.Path == "" {
+			return fmt.Errorf("LLM cassette path is required in %s mode", c.LLM.Cassette.Mode)
+		}
+	default:
+		return fmt.Errorf("invalid LLM cassette mode: %s", c.LLM.Cassette.Mode)
+	}
+	// Replayed calls never reach the provider, so no credentials are needed
+	needsKey := c.LLM.Cassette.Mode != "replay"
+
 	if c.LLM.Provider == "" {
 		return fmt.Errorf("LLM provider is required")
 	}
-	if c.LLM.APIKey == "" {
+	if needsKey && c.LLM.APIKey == "" && !keylessProvider(c.LLM.Provider) {
 		return fmt.Errorf("LLM API key is required")
 	}
+	if c.LLM.Provider == "fake" && c.LLM.FakeScript == "" {
+		return fmt.Errorf("LLM fake script is required for the fake provider")
+	}
 	if c.LLM.Model == "" {
 		return fmt.Errorf("LLM model is required")
 	}
+	for i, fb := range c.LLM.Fallbacks {
+		if fb.Provider == "" {
+			return fmt.Errorf("LLM fallback %d: provider is required", i+1)
+		}
+		if fb.Model == "" {
+			return fmt.Errorf("LLM fallback %d: model is required", i+1)
+		}
+		if needsKey && fb.Resolve(c.LLM).APIKey == "" && !keylessProvider(fb.Provider) {
+			return fmt.Errorf("LLM fallback %d: API key is required", i+1)
+		}
+		if fb.Provider == "fake" && fb.Resolve(c.LLM).FakeScript == "" {
+			return fmt.Errorf("LLM fallback %d: fake script is required", i+1)
+		}
+	}
+
+	if c.LLM.RetryConfig.Jitter < 0 || c.LLM.RetryConfig.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0.0 and 1.0")
+	}
+
+	for model, price := range c.LLM.Pricing {
+		if price.InputPerMillion < 0 || price.OutputPerMillion < 0 {
+			return fmt.Errorf("pricing for model %s must not be negative", model)
+		}
+	}
+
+	for phase, p := range c.LLM.Profiles {
+		if _, ok := profileParents[phase]; !ok {
+			return fmt.Errorf("unknown LLM profile phase: %s", phase)
+		}
+		if p.MaxTokens < 0 || p.Timeout < 0 {
+			return fmt.Errorf("LLM profile %s: max tokens and timeout must not be negative", phase)
+		}
+		if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+			return fmt.Errorf("LLM profile %s: temperature must be between 0 and 2", phase)
+		}
+	}
+
+	for model, caps := range c.LLM.Capabilities {
+		if caps.ContextWindow <= 0 {
+			return fmt.Errorf("capabilities for model %s: context window must be positive", model)
+		}
+		if caps.MaxOutputTokens < 0 || caps.MaxOutputTokens > caps.ContextWindow {
+			return fmt.Errorf("capabilities for model %s: max output tokens must be between 0 and the context window", model)
+		}
+	}
+
+	if c.LLM.RateLimit.RequestsPerMinute < 0 || c.LLM.RateLimit.TokensPerMinute < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+
+	if cb := c.LLM.CircuitBreaker; cb.Enabled {
+		if cb.FailureRatio <= 0 || cb.FailureRatio > 1 {
+			return fmt.Errorf("circuit breaker failure ratio must be between 0.0 and 1.0")
+		}
+		if cb.CoolDown <= 0 {
+			return fmt.Errorf("circuit breaker cool-down must be positive")
+		}
+	}
 
 	if c.Planning.MaxIterations <= 0 {
 		return fmt.Errorf("max iterations must be positive")
@@ -187,6 +550,38 @@ func (c *Config) Validate() error {
 	if c.Planning.MaxNodes <= 0 {
 		return fmt.Errorf("max nodes must be positive")
 	}
+	if c.Planning.RepairHistory < 0 {
+		return fmt.Errorf("repair history must not be negative")
+	}
+	if c.Planning.MaxContinuations < 0 {
+		return fmt.Errorf("max continuations must not be negative")
+	}
+	for complexity, route := range c.Planning.Routes {
+		switch complexity {
+		case "simple", "moderate", "complex":
+		default:
+			return fmt.Errorf("invalid route complexity: %s", complexity)
+		}
+		if route.MaxIterations < 0 || route.MaxTokens < 0 || route.MaxTokensBudget < 0 {
+			return fmt.Errorf("route %s: limits must not be negative", complexity)
+		}
+	}
+	strategies := c.Planning.Strategies
+	if strategies.Escalate.After < 0 || strategies.Restart.After < 0 || strategies.Temperature.Repeats < 0 {
+		return fmt.Errorf("strategy thresholds must not be negative")
+	}
+	if strategies.Escalate.After > 0 && strategies.Escalate.Model == "" {
+		return fmt.Errorf("escalate strategy requires a model")
+	}
+	if strategies.Temperature.Step < 0 || strategies.Temperature.Max < 0 || strategies.Temperature.Max > 2 {
+		return fmt.Errorf("temperature strategy step must not be negative and max must be between 0 and 2")
+	}
+	if c.Planning.Samples <= 0 {
+		return fmt.Errorf("samples must be positive")
+	}
+	if c.Planning.SampleTemperature < 0 || c.Planning.SampleTemperature > 2 {
+		return fmt.Errorf("sample temperature must be between 0 and 2")
+	}
 
 	validLogLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !validLogLevels[c.Logging.Level] {
@@ -195,3 +590,8 @@ func (c *Config) Validate() error {
 
 	return nil
 }
+
+// keylessProvider reports whether provider can run without an API key.
+func keylessProvider(provider string) bool {
+	return provider == "local" || provider == "fake"
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProfileDefaults(t *testing.T) {
+	tests := []struct {
+		name          string
+		profiles      string
+		wantModel     string
+		wantMaxTokens int
+	}{
+		{
+			name:          "no profiles",
+			wantMaxTokens: 1024,
+		},
+		{
+			name: "analysis model only",
+			profiles: `
+    analysis:
+      model: "small-model"`,
+			wantModel:     "small-model",
+			wantMaxTokens: 1024,
+		},
+		{
+			name: "analysis max_tokens set",
+			profiles: `
+    analysis:
+      max_tokens: 512`,
+			wantMaxTokens: 512,
+		},
+		{
+			name: "other phase only",
+			profiles: `
+    repair:
+      model: "repair-model"`,
+			wantMaxTokens: 1024,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := "llm:\n  provider: local\n  model: planner-model\n"
+			if tt.profiles != "" {
+				yaml += "  profiles:" + tt.profiles + "\n"
+			}
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			p := cfg.LLM.Profiles["analysis"]
+			if p.Model != tt.wantModel || p.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("analysis profile = %+v, want model %q and max_tokens %d", p, tt.wantModel, tt.wantMaxTokens)
+			}
+
+			// Unset fields still resolve from the top-level settings
+			want := tt.wantModel
+			if want == "" {
+				want = "planner-model"
+			}
+			if resolved := cfg.LLM.Profile("analysis"); resolved.Model != want {
+				t.Errorf("resolved analysis model = %q, want %q", resolved.Model, want)
+			}
+		})
+	}
+}
@@ -14,15 +14,50 @@
 //	llm:
 //	  provider: "anthropic"
 //	  api_key: "your-api-key"
+//	  base_url: ""  # openai and local only
+//	  auth_header: "" # defaults to Authorization (bearer token)
 //	  model: "claude-3-5-sonnet-20241022"
 //	  max_tokens: 4096
 //	  temperature: 0.0
 //	  timeout: 60s
+//	  structured_output: true
+//	  profiles:
+//	    analysis:
+//	      model: "claude-3-5-haiku-20241022"
+//	      max_tokens: 1024
+//	    repair:
+//	      temperature: 0.2
 //	  retry:
 //	    max_attempts: 3
 //	    initial_delay: 1s
 //	    max_delay: 10s
 //	    multiplier: 2.0
+//	    jitter: 0.2
+//	  fallbacks:
+//	    - provider: "openai"
+//	      api_key: "your-openai-key"
+//	      model: "gpt-4o"
+//	  circuit_breaker:
+//	    enabled: true
+//	    failure_ratio: 0.5
+//	    min_requests: 5
+//	    window: 60s
+//	    cool_down: 30s
+//	    half_open_requests: 1
+//	  rate_limit:
+//	    requests_per_minute: 50
+//	    tokens_per_minute: 40000
+//	  pricing:
+//	    claude-3-5-sonnet-20241022:
+//	      input_per_million: 3.0
+//	      output_per_million: 15.0
+//	  capabilities:
+//	    llama3.1:
+//	      context_window: 8192
+//	      max_output_tokens: 2048
+//	  cassette:
+//	    mode: "off"
+//	    path: "./testdata/cassettes/planner.json"
 //
 //	planning:
 //	  max_iterations: 3
@@ -30,7 +65,29 @@
 //	  prompt_path: "./prompts"
 //	  enable_validation: true
 //	  enable_analysis: true
+//	  enable_lint: true
 //	  confidence_threshold: 0.8
+//	  repair_history: 2
+//	  max_continuations: 2
+//	  samples: 1
+//	  sample_temperature: 0.7
+//	  routes:
+//	    simple:
+//	      model: "claude-3-5-haiku-20241022"
+//	      max_iterations: 2
+//	    complex:
+//	      max_iterations: 5
+//	      max_tokens_budget: 100000
+//	  strategies:
+//	    escalate:
+//	      after: 2
+//	      model: "claude-3-5-sonnet-20241022"
+//	    restart:
+//	      after: 3
+//	    temperature:
+//	      repeats: 2
+//	      step: 0.2
+//	      max: 1.0
 //
 //	logging:
 //	  level: "info"
@@ -40,14 +97,23 @@
 // Environment variables:
 //   - PLANNER_SERVER_HOST: Server host address
 //   - PLANNER_SERVER_PORT: Server port number
-//   - PLANNER_LLM_PROVIDER: LLM provider (anthropic, openai)
-//   - PLANNER_LLM_API_KEY: LLM API key
+//   - PLANNER_LLM_PROVIDER: LLM provider (anthropic, openai, local, fake)
+//   - PLANNER_LLM_API_KEY: LLM API key (optional for the local and fake providers)
+//   - PLANNER_LLM_BASE_URL: LLM API base URL (openai, local)
+//   - PLANNER_LLM_FAKE_SCRIPT: Response script for the fake provider
+//   - PLANNER_LLM_AUTH_HEADER: Header carrying the API key (default: Authorization)
 //   - PLANNER_LLM_MODEL: LLM model name
 //   - PLANNER_LLM_MAX_TOKENS: Maximum tokens for LLM responses
 //   - PLANNER_LLM_TEMPERATURE: LLM temperature (0.0-1.0)
+//   - PLANNER_LLM_CASSETTE_MODE: LLM cassette mode (off, record, replay)
+//   - PLANNER_LLM_CASSETTE_PATH: LLM cassette file
 //   - PLANNER_MAX_ITERATIONS: Maximum planning iterations
 //   - PLANNER_MAX_NODES: Maximum nodes per graph
 //   - PLANNER_PROMPT_PATH: Path to prompt template files
+//   - PLANNER_REPAIR_HISTORY: Failed attempts kept in repair conversations
+//   - PLANNER_MAX_CONTINUATIONS: Continuation requests per truncated response
+//   - PLANNER_SAMPLES: Candidate graphs generated per plan
+//   - PLANNER_SAMPLE_TEMPERATURE: Temperature of candidate generation
 //   - PLANNER_LOG_LEVEL: Logging level (debug, info, warn, error)
 //   - PLANNER_LOG_FORMAT: Logging format (json, console)
 //
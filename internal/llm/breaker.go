@@ -0,0 +1,193 @@
+package llm
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+// ErrCircuitOpen is returned when a request is rejected because the
+// circuit breaker of every available backend is open.
+var ErrCircuitOpen = errors.New("LLM circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets all requests through
+	CircuitClosed CircuitState = "closed"
+
+	// CircuitOpen rejects all requests until the cool-down elapses
+	CircuitOpen CircuitState = "open"
+
+	// CircuitHalfOpen lets a limited number of probe requests through
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// Breaker is a circuit breaker guarding calls to a single LLM backend.
+//
+// While closed it counts outcomes over a rolling window and opens once the
+// failure ratio reaches the configured threshold. After the cool-down it
+// becomes half-open and lets probe requests through: a successful probe
+// closes it again, a failed one re-opens it.
+type Breaker struct {
+	enabled          bool
+	failureRatio     float64
+	minRequests      int
+	window           time.Duration
+	coolDown         time.Duration
+	halfOpenRequests int
+
+	mu          sync.Mutex
+	state       CircuitState
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int
+	now         func() time.Time
+}
+
+// NewBreaker creates a new circuit breaker with the given configuration.
+func NewBreaker(cfg config.CircuitBreakerConfig) *Breaker {
+	halfOpenRequests := cfg.HalfOpenRequests
+	if halfOpenRequests <= 0 {
+		halfOpenRequests = 1
+	}
+
+	return &Breaker{
+		enabled:          cfg.Enabled,
+		failureRatio:     cfg.FailureRatio,
+		minRequests:      cfg.MinRequests,
+		window:           cfg.Window,
+		coolDown:         cfg.CoolDown,
+		halfOpenRequests: halfOpenRequests,
+		state:            CircuitClosed,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a request may proceed. Callers that are allowed
+// through must report the outcome with Record.
+func (b *Breaker) Allow() error {
+	if !b.enabled {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probes >= b.halfOpenRequests {
+			return ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	return nil
+}
+
+// Record reports the outcome of a request allowed by Allow.
+// Only transient provider failures count against the backend; cancelled
+// requests are ignored and permanent errors show the provider is reachable.
+func (b *Breaker) Record(err error) {
+	if !b.enabled {
+		return
+	}
+
+	failed := IsRetryable(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil && ClassifyError(err).Kind == ErrorKindCanceled {
+		// Give the probe slot back so a cancelled probe cannot wedge the breaker
+		if b.state == CircuitHalfOpen && b.probes > 0 {
+			b.probes--
+		}
+		return
+	}
+
+	now := b.now()
+
+	switch b.currentState() {
+	case CircuitHalfOpen:
+		if failed {
+			b.trip(now)
+		} else {
+			b.reset(now)
+		}
+
+	case CircuitClosed:
+		if b.window > 0 && now.Sub(b.windowStart) > b.window {
+			b.requests, b.failures = 0, 0
+			b.windowStart = now
+		}
+
+		b.requests++
+		if failed {
+			b.failures++
+		}
+
+		if b.requests >= b.minRequests && float64(b.failures)/float64(b.requests) >= b.failureRatio {
+			b.trip(now)
+		}
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() CircuitState {
+	if !b.enabled {
+		return CircuitClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.currentState()
+}
+
+// RetryAfter returns how long until an open breaker lets probes through.
+func (b *Breaker) RetryAfter() time.Duration {
+	if !b.enabled {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.currentState() != CircuitOpen {
+		return 0
+	}
+	return b.coolDown - b.now().Sub(b.openedAt)
+}
+
+// currentState moves an open breaker to half-open once the cool-down has
+// elapsed and returns the resulting state. The caller must hold b.mu.
+func (b *Breaker) currentState() CircuitState {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.coolDown {
+		b.state = CircuitHalfOpen
+		b.probes = 0
+	}
+	return b.state
+}
+
+// trip opens the breaker. The caller must hold b.mu.
+func (b *Breaker) trip(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.probes = 0
+}
+
+// reset closes the breaker and starts a new window. The caller must hold b.mu.
+func (b *Breaker) reset(now time.Time) {
+	b.state = CircuitClosed
+	b.requests, b.failures = 0, 0
+	b.windowStart = now
+	b.probes = 0
+}
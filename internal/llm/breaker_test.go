@@ -0,0 +1,196 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+var (
+	errTransient = NewStatusError(http.StatusServiceUnavailable, "busy", nil)
+	errPermanent = NewStatusError(http.StatusBadRequest, "bad request", nil)
+	errCanceled  = fmt.Errorf("request: %w", context.Canceled)
+)
+
+// testClock is a manually advanced clock for the breaker.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestBreaker returns a breaker reading the time from a test clock.
+func newTestBreaker(cfg config.CircuitBreakerConfig) (*Breaker, *testClock) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cfg.Enabled = true
+	b := NewBreaker(cfg)
+	b.now = clock.Now
+	return b, clock
+}
+
+// call runs one request through the breaker and records err as its outcome.
+func call(t *testing.T, b *Breaker, err error) {
+	t.Helper()
+	if allowErr := b.Allow(); allowErr != nil {
+		t.Fatalf("Allow() error = %v in state %s", allowErr, b.State())
+	}
+	b.Record(err)
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	b, clock := newTestBreaker(config.CircuitBreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      2,
+		Window:           time.Minute,
+		CoolDown:         30 * time.Second,
+		HalfOpenRequests: 1,
+	})
+
+	call(t, b, errTransient)
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("State() after one failure = %s, want closed below the minimum requests", got)
+	}
+	call(t, b, errTransient)
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("State() after two failures = %s, want open", got)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() while open error = %v, want ErrCircuitOpen", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	if got := b.RetryAfter(); got != 20*time.Second {
+		t.Errorf("RetryAfter() = %v, want the 20s left of the cool-down", got)
+	}
+
+	// A failed probe re-opens the breaker for a whole cool-down
+	clock.Advance(20 * time.Second)
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Fatalf("State() after the cool-down = %s, want half_open", got)
+	}
+	call(t, b, errTransient)
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("State() after a failed probe = %s, want open", got)
+	}
+	if got := b.RetryAfter(); got != 30*time.Second {
+		t.Errorf("RetryAfter() = %v, want a new 30s cool-down", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() for the probe error = %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() beyond the probe limit error = %v, want ErrCircuitOpen", err)
+	}
+	b.Record(nil)
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("State() after a successful probe = %s, want closed", got)
+	}
+
+	// Closing starts a new window: one more failure does not reach the minimum
+	call(t, b, errTransient)
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("State() after one failure in a new window = %s, want closed", got)
+	}
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome []error
+		advance time.Duration // before the last outcome
+		want    CircuitState
+	}{
+		{
+			name:    "ratio below the threshold",
+			outcome: []error{nil, nil, errTransient, nil},
+			want:    CircuitClosed,
+		},
+		{
+			name:    "ratio at the threshold",
+			outcome: []error{nil, errTransient, nil, errTransient},
+			want:    CircuitOpen,
+		},
+		{
+			name:    "permanent errors count as successes",
+			outcome: []error{errPermanent, errPermanent, errTransient, errPermanent},
+			want:    CircuitClosed,
+		},
+		{
+			name:    "failures of an expired window are forgotten",
+			outcome: []error{errTransient, errTransient, errTransient, nil},
+			advance: 2 * time.Minute,
+			want:    CircuitClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, clock := newTestBreaker(config.CircuitBreakerConfig{
+				FailureRatio: 0.5,
+				MinRequests:  4,
+				Window:       time.Minute,
+				CoolDown:     30 * time.Second,
+			})
+			last := len(tt.outcome) - 1
+			for _, err := range tt.outcome[:last] {
+				call(t, b, err)
+			}
+			clock.Advance(tt.advance)
+			call(t, b, tt.outcome[last])
+
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerIgnoresCanceled(t *testing.T) {
+	b, clock := newTestBreaker(config.CircuitBreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      2,
+		Window:           time.Minute,
+		CoolDown:         30 * time.Second,
+		HalfOpenRequests: 1,
+	})
+
+	call(t, b, errCanceled)
+	call(t, b, errCanceled)
+	call(t, b, errTransient)
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("State() = %s, want closed: cancelled calls must not count as requests", got)
+	}
+
+	call(t, b, errTransient)
+	clock.Advance(30 * time.Second)
+
+	// A cancelled probe gives its slot back
+	call(t, b, errCanceled)
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Fatalf("State() after a cancelled probe = %s, want half_open", got)
+	}
+	call(t, b, nil)
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("State() after a successful probe = %s, want closed", got)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := NewBreaker(config.CircuitBreakerConfig{FailureRatio: 0.1, MinRequests: 1})
+
+	for range 3 {
+		call(t, b, errTransient)
+	}
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("State() = %s, want closed for a disabled breaker", got)
+	}
+}
@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+// ErrContextWindowExceeded is returned when a request cannot fit in the
+// context window of the model, even after trimming.
+var ErrContextWindowExceeded = errors.New("request exceeds the model context window")
+
+// defaultCapabilities are the token limits of well-known models. Entries of
+// the llm.capabilities configuration take precedence.
+//
+// Variants named after "gpt-4-" that belong to other families are listed
+// explicitly so that they do not fall back to the 8k window of "gpt-4".
+var defaultCapabilities = map[string]config.ModelCapabilities{
+	"claude-3-5-sonnet":    {ContextWindow: 200000, MaxOutputTokens: 8192},
+	"claude-3-5-haiku":     {ContextWindow: 200000, MaxOutputTokens: 8192},
+	"claude-3-opus":        {ContextWindow: 200000, MaxOutputTokens: 4096},
+	"claude-3-sonnet":      {ContextWindow: 200000, MaxOutputTokens: 4096},
+	"claude-3-haiku":       {ContextWindow: 200000, MaxOutputTokens: 4096},
+	"gpt-4.1":              {ContextWindow: 1047576, MaxOutputTokens: 32768},
+	"gpt-4o":               {ContextWindow: 128000, MaxOutputTokens: 16384},
+	"gpt-4o-mini":          {ContextWindow: 128000, MaxOutputTokens: 16384},
+	"gpt-4-turbo":          {ContextWindow: 128000, MaxOutputTokens: 4096},
+	"gpt-4-1106-preview":   {ContextWindow: 128000, MaxOutputTokens: 4096},
+	"gpt-4-0125-preview":   {ContextWindow: 128000, MaxOutputTokens: 4096},
+	"gpt-4-vision-preview": {ContextWindow: 128000, MaxOutputTokens: 4096},
+	"gpt-4-32k":            {ContextWindow: 32768, MaxOutputTokens: 8192},
+	"gpt-4":                {ContextWindow: 8192, MaxOutputTokens: 8192},
+	"gpt-3.5-turbo":        {ContextWindow: 16385, MaxOutputTokens: 4096},
+}
+
+// Capabilities looks up the token limits of models.
+type Capabilities struct {
+	table map[string]config.ModelCapabilities
+}
+
+// NewCapabilities creates a capability table from the built-in defaults
+// and the configured overrides.
+func NewCapabilities(overrides map[string]config.ModelCapabilities) *Capabilities {
+	table := make(map[string]config.ModelCapabilities, len(defaultCapabilities)+len(overrides))
+	for model, caps := range defaultCapabilities {
+		table[model] = caps
+	}
+	for model, caps := range overrides {
+		table[model] = caps
+	}
+
+	return &Capabilities{table: table}
+}
+
+// Lookup returns the limits of model and whether they are known. Models are
+// matched exactly first, then by the longest prefix ending at a "-" so that
+// dated variants (e.g. "claude-3-5-sonnet-20241022") pick up the limits of
+// their family while other families (e.g. "gpt-4o" for "gpt-4") do not.
+func (c *Capabilities) Lookup(model string) (config.ModelCapabilities, bool) {
+	if model == "" {
+		return config.ModelCapabilities{}, false
+	}
+
+	if caps, ok := c.table[model]; ok {
+		return caps, true
+	}
+
+	var best string
+	for name := range c.table {
+		if variantOf(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return config.ModelCapabilities{}, false
+	}
+	return c.table[best], true
+}
+
+// variantOf reports whether model is a variant of the family name: name
+// followed by a "-" and a suffix.
+func variantOf(model, name string) bool {
+	return strings.HasPrefix(model, name+"-")
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+func TestCapabilitiesLookup(t *testing.T) {
+	caps := NewCapabilities(map[string]config.ModelCapabilities{
+		"llama3":      {ContextWindow: 8192, MaxOutputTokens: 2048},
+		"gpt-4o-mini": {ContextWindow: 64000, MaxOutputTokens: 4096},
+	})
+
+	tests := []struct {
+		model      string
+		wantWindow int
+		wantOK     bool
+	}{
+		{model: "gpt-4", wantWindow: 8192, wantOK: true},
+		{model: "gpt-4-0613", wantWindow: 8192, wantOK: true},
+		{model: "gpt-4-32k", wantWindow: 32768, wantOK: true},
+		{model: "gpt-4-32k-0613", wantWindow: 32768, wantOK: true},
+		{model: "gpt-4-turbo", wantWindow: 128000, wantOK: true},
+		{model: "gpt-4-turbo-2024-04-09", wantWindow: 128000, wantOK: true},
+		{model: "gpt-4-0125-preview", wantWindow: 128000, wantOK: true},
+		{model: "gpt-4o", wantWindow: 128000, wantOK: true},
+		{model: "gpt-4o-2024-08-06", wantWindow: 128000, wantOK: true},
+		{model: "gpt-4.1", wantWindow: 1047576, wantOK: true},
+		{model: "gpt-4.1-mini", wantWindow: 1047576, wantOK: true},
+		{model: "gpt-4.5-preview", wantOK: false},
+		{model: "gpt-4omni", wantOK: false},
+		{model: "claude-3-5-sonnet-20241022", wantWindow: 200000, wantOK: true},
+		{model: "claude-3-5-sonnetx", wantOK: false},
+
+		// Configured entries override and extend the defaults
+		{model: "gpt-4o-mini-2024-07-18", wantWindow: 64000, wantOK: true},
+		{model: "llama3", wantWindow: 8192, wantOK: true},
+		{model: "llama3-8b", wantWindow: 8192, wantOK: true},
+		{model: "llama3.1", wantOK: false},
+
+		{model: "", wantOK: false},
+		{model: "unknown-model", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, ok := caps.Lookup(tt.model)
+			if ok != tt.wantOK {
+				t.Fatalf("Lookup(%q) ok = %v, want %v", tt.model, ok, tt.wantOK)
+			}
+			if ok && got.ContextWindow != tt.wantWindow {
+				t.Errorf("Lookup(%q) context window = %d, want %d", tt.model, got.ContextWindow, tt.wantWindow)
+			}
+		})
+	}
+}
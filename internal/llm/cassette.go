@@ -0,0 +1,404 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"go.uber.org/zap"
+)
+
+// Cassette modes.
+const (
+	// CassetteOff disables recording and replay
+	CassetteOff = "off"
+
+	// CassetteRecord forwards calls to the provider and records them
+	CassetteRecord = "record"
+
+	// CassetteReplay serves recorded responses without a provider
+	CassetteReplay = "replay"
+)
+
+// cassetteVersion is the version of the cassette file format.
+const cassetteVersion = 1
+
+// ErrCassetteMiss is returned in replay mode when no recorded response
+// matches a request.
+var ErrCassetteMiss = errors.New("no recorded response for request")
+
+// Cassette stores LLM request/response pairs for deterministic replay.
+//
+// Interactions are keyed by a hash of the model, messages and generation
+// parameters. A request made several times is recorded once per call and
+// the responses are replayed in order; once they run out the last one is
+// repeated. A cassette is shared by every backend it wraps.
+type Cassette struct {
+	mode   string
+	path   string
+	logger *zap.Logger
+
+	mu           sync.Mutex
+	interactions map[string][]ports.CompletionResponse
+	requests     map[string]ports.CompletionRequest
+	schemas      map[string]ports.JSONSchema
+	keys         []string
+	cursor       map[string]int
+}
+
+// cassetteFile is the on-disk format of a cassette.
+type cassetteFile struct {
+	Version      int                   `json:"version"`
+	Interactions []cassetteInteraction `json:"interactions"`
+}
+
+// cassetteInteraction is a recorded request with its responses in call order.
+// Structured responses are stored with their data encoded as message content.
+type cassetteInteraction struct {
+	Key       string                     `json:"key"`
+	Request   ports.CompletionRequest    `json:"request"`
+	Schema    ports.JSONSchema           `json:"schema,omitempty"`
+	Responses []ports.CompletionResponse `json:"responses"`
+}
+
+// OpenCassette opens the cassette described by cfg. It returns nil if
+// cassettes are disabled. In replay mode the file must exist; in record
+// mode any existing file is overwritten as calls are recorded.
+func OpenCassette(cfg config.CassetteConfig, logger *zap.Logger) (*Cassette, error) {
+	if cfg.Mode == "" || cfg.Mode == CassetteOff {
+		return nil, nil
+	}
+
+	if cfg.Mode != CassetteRecord && cfg.Mode != CassetteReplay {
+		return nil, fmt.Errorf("unsupported cassette mode: %s", cfg.Mode)
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("cassette path is required")
+	}
+
+	c := &Cassette{
+		mode:         cfg.Mode,
+		path:         cfg.Path,
+		logger:       logger,
+		interactions: make(map[string][]ports.CompletionResponse),
+		requests:     make(map[string]ports.CompletionRequest),
+		schemas:      make(map[string]ports.JSONSchema),
+		cursor:       make(map[string]int),
+	}
+
+	if cfg.Mode == CassetteReplay {
+		if err := c.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Info("LLM cassette enabled",
+		zap.String("mode", cfg.Mode),
+		zap.String("path", cfg.Path),
+		zap.Int("interactions", len(c.keys)),
+	)
+
+	return c, nil
+}
+
+// Mode returns the cassette mode.
+func (c *Cassette) Mode() string {
+	return c.mode
+}
+
+// Wrap returns an LLM client that records calls to inner or, in replay
+// mode, serves them from the cassette. inner may be nil in replay mode.
+func (c *Cassette) Wrap(inner ports.LLMClient) ports.LLMClient {
+	return &cassetteClient{cassette: c, inner: inner}
+}
+
+// load reads the cassette file.
+func (c *Cassette) load() error {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse cassette: %w", err)
+	}
+	if file.Version != cassetteVersion {
+		return fmt.Errorf("unsupported cassette version: %d", file.Version)
+	}
+
+	for _, interaction := range file.Interactions {
+		if _, ok := c.interactions[interaction.Key]; !ok {
+			c.keys = append(c.keys, interaction.Key)
+		}
+		c.requests[interaction.Key] = interaction.Request
+		if interaction.Schema != nil {
+			c.schemas[interaction.Key] = interaction.Schema
+		}
+		c.interactions[interaction.Key] = append(c.interactions[interaction.Key], interaction.Responses...)
+	}
+
+	return nil
+}
+
+// save writes the cassette file atomically. The caller must hold c.mu.
+func (c *Cassette) save() error {
+	file := cassetteFile{Version: cassetteVersion}
+	for _, key := range c.keys {
+		file.Interactions = append(file.Interactions, cassetteInteraction{
+			Key:       key,
+			Request:   c.requests[key],
+			Schema:    c.schemas[key],
+			Responses: c.interactions[key],
+		})
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// record appends a response for req and persists the cassette. schema is
+// nil for plain text completions.
+func (c *Cassette) record(req ports.CompletionRequest, schema ports.JSONSchema, resp *ports.CompletionResponse) error {
+	key := cassetteKey(req, schema)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.interactions[key]; !ok {
+		c.keys = append(c.keys, key)
+		c.requests[key] = req
+		if schema != nil {
+			c.schemas[key] = schema
+		}
+	}
+	c.interactions[key] = append(c.interactions[key], *resp)
+
+	return c.save()
+}
+
+// replay returns the next recorded response for req.
+func (c *Cassette) replay(req ports.CompletionRequest, schema ports.JSONSchema) (*ports.CompletionResponse, error) {
+	key := cassetteKey(req, schema)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	responses := c.interactions[key]
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("%w (model %q, key %s)", ErrCassetteMiss, req.Model, key[:12])
+	}
+
+	i := c.cursor[key]
+	if i >= len(responses) {
+		i = len(responses) - 1
+	} else {
+		c.cursor[key] = i + 1
+	}
+
+	resp := responses[i]
+	return &resp, nil
+}
+
+// hasStructured reports whether any structured completion was recorded.
+func (c *Cassette) hasStructured() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.schemas) > 0
+}
+
+// cassetteKey hashes the fields of a request, and the response schema of
+// structured completions, that determine its response.
+func cassetteKey(req ports.CompletionRequest, schema ports.JSONSchema) string {
+	keyed := struct {
+		Model            string           `json:"model"`
+		Messages         []ports.Message  `json:"messages"`
+		Temperature      float64          `json:"temperature"`
+		MaxTokens        int              `json:"max_tokens"`
+		TopP             float64          `json:"top_p"`
+		Stop             []string         `json:"stop"`
+		PresencePenalty  float64          `json:"presence_penalty"`
+		FrequencyPenalty float64          `json:"frequency_penalty"`
+		Schema           ports.JSONSchema `json:"schema,omitempty"`
+	}{
+		Model:            req.Model,
+		Messages:         req.Messages,
+		Temperature:      req.Temperature,
+		MaxTokens:        req.MaxTokens,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Schema:           schema,
+	}
+
+	data, _ := json.Marshal(keyed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cassetteClient is the ports.LLMClient decorator returned by Cassette.Wrap.
+type cassetteClient struct {
+	cassette *Cassette
+	inner    ports.LLMClient
+}
+
+// Complete performs a standard text completion (ports.LLMClient interface).
+func (c *cassetteClient) Complete(ctx context.Context, req ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	if c.cassette.mode == CassetteReplay {
+		return c.cassette.replay(req, nil)
+	}
+
+	resp, err := c.inner.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.cassette.record(req, nil, resp); err != nil {
+		c.cassette.logger.Error("failed to record LLM interaction",
+			zap.String("path", c.cassette.path),
+			zap.Error(err),
+		)
+	}
+
+	return resp, nil
+}
+
+// CompleteWithTools performs a completion with tool calling support (ports.LLMClient interface).
+// Tool calls are not recorded.
+func (c *cassetteClient) CompleteWithTools(ctx context.Context, req ports.CompletionRequest, tools []ports.Tool) (*ports.CompletionResponse, error) {
+	if c.cassette.mode == CassetteReplay {
+		return nil, fmt.Errorf("tool calls are not supported in cassette replay mode")
+	}
+	return c.inner.CompleteWithTools(ctx, req, tools)
+}
+
+// CompleteStructured performs a completion with guaranteed JSON schema conformance (ports.LLMClient interface).
+func (c *cassetteClient) CompleteStructured(ctx context.Context, req ports.CompletionRequest, schema ports.JSONSchema) (*ports.StructuredResponse, error) {
+	if c.cassette.mode == CassetteReplay {
+		resp, err := c.cassette.replay(req, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.FinishReason == "length" {
+			return nil, &TruncatedError{Content: resp.Message.Content, Usage: resp.Usage}
+		}
+
+		data, err := decodeStructured(resp.Message.Content, resp.Usage)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ports.StructuredResponse{
+			Data:      data,
+			Usage:     resp.Usage,
+			CreatedAt: resp.CreatedAt,
+		}, nil
+	}
+
+	resp, err := c.inner.CompleteStructured(ctx, req, schema)
+	var truncated *TruncatedError
+	if errors.As(err, &truncated) {
+		if recErr := c.cassette.record(req, schema, &ports.CompletionResponse{
+			Model:        req.Model,
+			Message:      ports.Message{Role: "assistant", Content: truncated.Content},
+			FinishReason: "length",
+			Usage:        truncated.Usage,
+			CreatedAt:    time.Now(),
+		}); recErr != nil {
+			c.cassette.logger.Error("failed to record LLM interaction",
+				zap.String("path", c.cassette.path),
+				zap.Error(recErr),
+			)
+		}
+		return nil, err
+	}
+	var unstructured *UnstructuredError
+	if errors.As(err, &unstructured) {
+		if recErr := c.cassette.record(req, schema, &ports.CompletionResponse{
+			Model:        req.Model,
+			Message:      ports.Message{Role: "assistant", Content: unstructured.Content},
+			FinishReason: "stop",
+			Usage:        unstructured.Usage,
+			CreatedAt:    time.Now(),
+		}); recErr != nil {
+			c.cassette.logger.Error("failed to record LLM interaction",
+				zap.String("path", c.cassette.path),
+				zap.Error(recErr),
+			)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(resp.Data)
+	if err == nil {
+		err = c.cassette.record(req, schema, &ports.CompletionResponse{
+			Model:        req.Model,
+			Message:      ports.Message{Role: "assistant", Content: string(content)},
+			FinishReason: "stop",
+			Usage:        resp.Usage,
+			CreatedAt:    resp.CreatedAt,
+		})
+	}
+	if err != nil {
+		c.cassette.logger.Error("failed to record LLM interaction",
+			zap.String("path", c.cassette.path),
+			zap.Error(err),
+		)
+	}
+
+	return resp, nil
+}
+
+// SupportsStructuredOutput reports whether structured completions can be
+// recorded or replayed (llm.StructuredLLMClient interface). In replay mode
+// this holds when the cassette contains structured completions.
+func (c *cassetteClient) SupportsStructuredOutput() bool {
+	if c.cassette.mode == CassetteReplay {
+		return c.cassette.hasStructured()
+	}
+
+	s, ok := c.inner.(StructuredLLMClient)
+	return ok && s.SupportsStructuredOutput()
+}
+
+// GenerateCompletion generates a completion using domain.LLMRequest (compatibility method).
+// Compatibility calls are not recorded.
+func (c *cassetteClient) GenerateCompletion(ctx context.Context, req interface{}) (interface{}, error) {
+	if c.cassette.mode == CassetteReplay {
+		return nil, fmt.Errorf("GenerateCompletion is not supported in cassette replay mode")
+	}
+	return c.inner.GenerateCompletion(ctx, req)
+}
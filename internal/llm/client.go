@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aescanero/dago-libs/pkg/ports"
 	"github.com/aescanero/dago-node-planner/internal/config"
@@ -11,57 +13,190 @@ import (
 
 // Client wraps an LLM client with planner-specific functionality.
 type Client struct {
+	backends []*backend
+	config   *config.LLMConfig
+	retrier  *Retrier
+	pricing  *Pricing
+	caps     *Capabilities
+	logger   *zap.Logger
+
+	statsMu sync.Mutex
+	stats   UsageStats
+}
+
+// backend is one provider/model entry of the fallback chain.
+type backend struct {
+	provider  string
+	model     string
 	llmClient ports.LLMClient
-	config    *config.LLMConfig
-	retrier   *Retrier
-	logger    *zap.Logger
-	stats     *UsageStats
+	breaker   *Breaker
+	limiter   *RateLimiter
+}
+
+// BackendStatus reports the health of one backend of the fallback chain.
+type BackendStatus struct {
+	Provider string       `json:"provider"`
+	Model    string       `json:"model"`
+	Circuit  CircuitState `json:"circuit"`
 }
 
 // NewClient creates a new LLM client for the planner.
+// The given client is the primary backend; fallbacks can be added with AddFallback.
 func NewClient(llmClient ports.LLMClient, cfg *config.LLMConfig, logger *zap.Logger) *Client {
 	return &Client{
-		llmClient: llmClient,
-		config:    cfg,
-		retrier:   NewRetrier(cfg.RetryConfig),
-		logger:    logger,
-		stats:     &UsageStats{},
+		backends: []*backend{{
+			provider:  cfg.Provider,
+			model:     cfg.Model,
+			llmClient: llmClient,
+			breaker:   NewBreaker(cfg.CircuitBreaker),
+			limiter:   NewRateLimiter(cfg.RateLimit),
+		}},
+		config:  cfg,
+		retrier: NewRetrier(cfg.RetryConfig),
+		pricing: NewPricing(cfg.Pricing),
+		caps:    NewCapabilities(cfg.Capabilities),
+		logger:  logger,
 	}
 }
 
+// AddFallback appends a backend to the fallback chain. Backends are tried
+// in the order they were added once the previous one has failed.
+func (c *Client) AddFallback(provider, model string, llmClient ports.LLMClient) {
+	c.backends = append(c.backends, &backend{
+		provider:  provider,
+		model:     model,
+		llmClient: llmClient,
+		breaker:   NewBreaker(c.config.CircuitBreaker),
+		limiter:   NewRateLimiter(c.config.RateLimit),
+	})
+}
+
 // Complete sends a completion request to the LLM with retry logic.
+// If a backend exhausts its retries, the next one in the fallback chain is tried.
+// Backends whose circuit breaker is open are skipped; if none is available
+// the returned error wraps ErrCircuitOpen. Calls that would exceed the
+// plan budget carried by the context fail with ErrBudgetExhausted, and
+// calls that fit the context window of no backend with
+// ErrContextWindowExceeded.
 func (c *Client) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
 	c.logger.Debug("sending LLM completion request",
 		zap.Int("max_tokens", req.MaxTokens),
 		zap.Float64("temperature", req.Temperature),
 	)
 
-	var resp *CompletionResponse
-	var err error
-
-	// Execute with retry
-	err = c.retrier.Do(ctx, func() error {
-		resp, err = c.doComplete(ctx, req)
-		return err
+	return c.execute(ctx, req, func(ctx context.Context, b *backend) (*CompletionResponse, error) {
+		return c.doComplete(ctx, b, req)
 	})
+}
 
+// execute runs call against the fallback chain with retries, rate limiting,
+// circuit breaking, budget checks and usage accounting. Each attempt is
+// bounded by the request timeout.
+func (c *Client) execute(
+	ctx context.Context,
+	req *CompletionRequest,
+	call func(ctx context.Context, b *backend) (*CompletionResponse, error),
+) (*CompletionResponse, error) {
+	release, err := c.reserveBudget(ctx, req)
 	if err != nil {
-		c.stats.AddCall(0, false)
 		return nil, err
 	}
+	// Usage is recorded before the reservation is released
+	defer release()
 
-	c.stats.AddCall(resp.TokensUsed, true)
+	var lastErr error
 
-	c.logger.Debug("received LLM response",
-		zap.Int("tokens_used", resp.TokensUsed),
-		zap.String("finish_reason", resp.FinishReason),
-	)
+	for i, b := range c.backends {
+		if i > 0 {
+			c.logger.Warn("falling back to next LLM backend",
+				zap.String("provider", b.provider),
+				zap.String("model", b.model),
+				zap.Error(lastErr),
+			)
+		}
+
+		if err := c.checkContextWindow(b, req); err != nil {
+			lastErr = err
+			continue
+		}
+
+		// Rate limits are charged the prompt plus the full output allowance;
+		// the unused part is refunded once the actual usage is known, and
+		// all of it for attempts the provider rejected
+		reserved := estimatePromptTokens(req) + c.outputTokens(b, req)
+
+		var resp *CompletionResponse
+		var err error
+
+		// Execute with retry, consulting the limiter and breaker on every attempt
+		err = c.retrier.Do(ctx, func() error {
+			if err := b.limiter.Wait(ctx, reserved); err != nil {
+				return err
+			}
+			if err := b.breaker.Allow(); err != nil {
+				b.limiter.Refund(reserved)
+				return err
+			}
+			attemptCtx, cancel := c.attemptContext(ctx, req)
+			defer cancel()
+			resp, err = call(attemptCtx, b)
+			b.breaker.Record(err)
+			if err != nil && !ClassifyError(err).tokensSpent() {
+				b.limiter.Refund(reserved)
+			}
+			return err
+		})
+
+		if err == nil {
+			b.limiter.Refund(reserved - resp.TokensUsed)
+
+			c.recordCall(ctx, req, resp, true)
+
+			c.logger.Debug("received LLM response",
+				zap.String("provider", resp.Provider),
+				zap.String("model", resp.Model),
+				zap.Int("input_tokens", resp.InputTokens),
+				zap.Int("output_tokens", resp.OutputTokens),
+				zap.String("finish_reason", resp.FinishReason),
+			)
+
+			return resp, nil
+		}
+
+		lastErr = err
+
+		// Cancelled requests and streams that already delivered output
+		// must not spill over to the fallbacks
+		if ctx.Err() != nil || !ClassifyError(err).fallbackAllowed() {
+			break
+		}
+	}
 
-	return resp, nil
+	c.recordCall(ctx, req, nil, false)
+
+	if len(c.backends) > 1 && ctx.Err() == nil {
+		return nil, fmt.Errorf("all %d LLM backends failed: %w", len(c.backends), lastErr)
+	}
+	return nil, lastErr
 }
 
-// doComplete performs a single completion request without retry.
-func (c *Client) doComplete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+// doComplete performs a single completion request against a backend without retry.
+func (c *Client) doComplete(ctx context.Context, b *backend, req *CompletionRequest) (*CompletionResponse, error) {
+	if c.structured(b, req) {
+		return c.doStructured(ctx, b, req)
+	}
+
+	// Call LLM
+	llmResp, err := b.llmClient.Complete(ctx, c.buildRequest(b, req))
+	if err != nil {
+		return nil, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	return c.buildResponse(b, req, llmResp.Message.Content, llmResp.Model, llmResp.FinishReason, llmResp.Usage), nil
+}
+
+// buildRequest converts a planner request into a provider request for a backend.
+func (c *Client) buildRequest(b *backend, req *CompletionRequest) ports.CompletionRequest {
 	// Build messages
 	messages := []ports.Message{}
 
@@ -73,44 +208,244 @@ func (c *Client) doComplete(ctx context.Context, req *CompletionRequest) (*Compl
 		})
 	}
 
+	// Add earlier turns of the conversation
+	messages = append(messages, req.Messages...)
+
 	// Add user prompt
 	messages = append(messages, ports.Message{
 		Role:    "user",
 		Content: req.UserPrompt,
 	})
 
-	// Create LLM request
-	llmReq := ports.CompletionRequest{
-		Model:       c.config.Model,
+	return ports.CompletionRequest{
+		Model:       c.model(b, req),
 		Messages:    messages,
-		MaxTokens:   req.MaxTokens,
+		MaxTokens:   c.outputTokens(b, req),
 		Temperature: req.Temperature,
 		Stop:        req.StopSequences,
 	}
+}
 
-	// Call LLM
-	llmResp, err := c.llmClient.Complete(ctx, llmReq)
-	if err != nil {
-		return nil, fmt.Errorf("LLM request failed: %w", err)
+// model returns the model req is sent to on a backend: the model of the
+// request on the primary backend, the backend's own model otherwise.
+func (c *Client) model(b *backend, req *CompletionRequest) string {
+	if req.Model != "" && b == c.backends[0] {
+		return req.Model
+	}
+	return b.model
+}
+
+// attemptContext bounds one attempt of req by its timeout, if any.
+func (c *Client) attemptContext(ctx context.Context, req *CompletionRequest) (context.Context, context.CancelFunc) {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = c.config.Timeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// outputTokens returns the output allowance of req on a backend: its
+// MaxTokens, or the configured default if unset, capped at the maximum
+// output of the model.
+func (c *Client) outputTokens(b *backend, req *CompletionRequest) int {
+	tokens := req.MaxTokens
+	if tokens <= 0 {
+		tokens = c.config.MaxTokens
+	}
+
+	if caps, ok := c.caps.Lookup(c.model(b, req)); ok && caps.MaxOutputTokens > 0 && tokens > caps.MaxOutputTokens {
+		tokens = caps.MaxOutputTokens
+	}
+	return tokens
+}
+
+// checkContextWindow fails with ErrContextWindowExceeded if the prompt of
+// req plus its output allowance does not fit the context window of the
+// backend's model. Models of unknown capabilities are not checked.
+func (c *Client) checkContextWindow(b *backend, req *CompletionRequest) error {
+	model := c.model(b, req)
+	caps, ok := c.caps.Lookup(model)
+	if !ok {
+		return nil
 	}
 
-	// Extract response
+	promptTokens := estimatePromptTokens(req)
+	outputTokens := c.outputTokens(b, req)
+	if promptTokens+outputTokens <= caps.ContextWindow {
+		return nil
+	}
+
+	return &Error{
+		Kind: ErrorKindBadRequest,
+		Message: fmt.Sprintf("prompt of about %d tokens plus %d output tokens exceeds the %d token context window of %s",
+			promptTokens, outputTokens, caps.ContextWindow, model),
+		Err: ErrContextWindowExceeded,
+	}
+}
+
+// PromptBudget returns how many tokens the user prompt of req may take so
+// that the request fits the context window of every backend whose model
+// capabilities are known, given its system prompt, earlier turns and
+// output allowance. The second result is false if no limit is known.
+func (c *Client) PromptBudget(req *CompletionRequest) (int, bool) {
+	budget, known := 0, false
+
+	for _, b := range c.backends {
+		caps, ok := c.caps.Lookup(c.model(b, req))
+		if !ok {
+			continue
+		}
+
+		available := caps.ContextWindow - c.outputTokens(b, req) - estimateContextTokens(req) - messageOverhead
+		if !known || available < budget {
+			budget, known = available, true
+		}
+	}
+
+	return max(budget, 0), known
+}
+
+// buildResponse assembles a planner response from provider output.
+func (c *Client) buildResponse(
+	b *backend,
+	req *CompletionRequest,
+	content, model, finishReason string,
+	usage ports.UsageInfo,
+) *CompletionResponse {
 	resp := &CompletionResponse{
-		Content:      llmResp.Message.Content,
-		Model:        llmResp.Model,
-		TokensUsed:   llmResp.Usage.TotalTokens,
-		FinishReason: llmResp.FinishReason,
+		Content:      content,
+		Provider:     b.provider,
+		Model:        model,
+		TokensUsed:   usage.TotalTokens,
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		FinishReason: finishReason,
+	}
+
+	if resp.TokensUsed == 0 {
+		resp.TokensUsed = resp.InputTokens + resp.OutputTokens
+	}
+
+	// Not every provider echoes the model back
+	if resp.Model == "" {
+		resp.Model = c.model(b, req)
+	}
+
+	resp.Cost = c.estimateCost(c.model(b, req), resp)
+
+	return resp
+}
+
+// Provider returns the name of the primary LLM provider.
+func (c *Client) Provider() string {
+	return c.backends[0].provider
+}
+
+// Model returns the primary model name.
+func (c *Client) Model() string {
+	return c.backends[0].model
+}
+
+// Status returns the health of every backend in the fallback chain.
+func (c *Client) Status() []BackendStatus {
+	statuses := make([]BackendStatus, 0, len(c.backends))
+	for _, b := range c.backends {
+		statuses = append(statuses, BackendStatus{
+			Provider: b.provider,
+			Model:    b.model,
+			Circuit:  b.breaker.State(),
+		})
 	}
+	return statuses
+}
+
+// Available reports whether at least one backend accepts requests.
+func (c *Client) Available() bool {
+	for _, b := range c.backends {
+		if b.breaker.State() != CircuitOpen {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryAfter returns how long until the first backend with an open
+// circuit lets requests through again, or zero if one is available now.
+func (c *Client) RetryAfter() time.Duration {
+	var wait time.Duration
+	for i, b := range c.backends {
+		d := b.breaker.RetryAfter()
+		if d <= 0 {
+			return 0
+		}
+		if i == 0 || d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// reserveBudget reserves the estimated usage of req against the budget of
+// the plan carried by ctx, failing with ErrBudgetExhausted if it does not
+// fit. The call is estimated pessimistically: the prompt size plus the full
+// output allowance, priced at the primary model. The returned function
+// releases the reservation once the call has been recorded.
+func (c *Client) reserveBudget(ctx context.Context, req *CompletionRequest) (func(), error) {
+	usage := PlanUsageFromContext(ctx)
+	if usage == nil || usage.Budget().IsZero() {
+		return func() {}, nil
+	}
+
+	inputTokens := estimatePromptTokens(req)
+	outputTokens := c.outputTokens(c.backends[0], req)
+	cost, _ := c.pricing.Cost(c.model(c.backends[0], req), inputTokens, outputTokens)
 
-	return resp, nil
+	return usage.Reserve(inputTokens+outputTokens, cost)
 }
 
-// GetStats returns current usage statistics.
-func (c *Client) GetStats() *UsageStats {
+// estimateCost prices a response, preferring the configured model name
+// and falling back to the one echoed by the provider.
+func (c *Client) estimateCost(model string, resp *CompletionResponse) float64 {
+	if cost, ok := c.pricing.Cost(model, resp.InputTokens, resp.OutputTokens); ok {
+		return cost
+	}
+	if cost, ok := c.pricing.Cost(resp.Model, resp.InputTokens, resp.OutputTokens); ok {
+		return cost
+	}
+
+	c.logger.Debug("no pricing configured for model, cost not estimated",
+		zap.String("model", resp.Model),
+	)
+	return 0
+}
+
+// recordCall adds a call to the process-wide statistics and, if the
+// context carries one, to the per-plan usage accumulator.
+func (c *Client) recordCall(ctx context.Context, req *CompletionRequest, resp *CompletionResponse, success bool) {
+	c.statsMu.Lock()
+	c.stats.AddCall(resp, success)
+	c.statsMu.Unlock()
+
+	if usage := PlanUsageFromContext(ctx); usage != nil {
+		usage.Add(req.Phase, req.Iteration, resp, success)
+	}
+}
+
+// GetStats returns a snapshot of the process-wide usage statistics.
+func (c *Client) GetStats() UsageStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
 	return c.stats
 }
 
 // ResetStats resets usage statistics.
 func (c *Client) ResetStats() {
-	c.stats = &UsageStats{}
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	c.stats = UsageStats{}
 }
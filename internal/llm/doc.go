@@ -1,7 +1,16 @@
 // Package llm provides LLM integration for the dago-node-planner.
 //
 // This package wraps the LLM client from dago-adapters and provides:
-//   - Retry logic with exponential backoff
+//   - Retry logic with exponential backoff, jitter and Retry-After support
+//   - Typed provider errors distinguishing transient from permanent failures
+//   - Multi-model fallback chain with a circuit breaker per backend
+//   - Client-side request and token rate limits per backend
+//   - Model capability table (context window, max output) and context
+//     window checks before requests are sent
+//   - Streaming completions (CompleteStream) with a single-chunk fallback
+//   - Record/replay cassettes for deterministic, offline runs
+//   - Native structured output for requests carrying a ResponseSchema
+//   - Per-phase profiles of model, max tokens, temperature and timeout
 //   - Request/response models specific to planning
 //   - Usage statistics tracking
 //   - Planner-specific error handling
@@ -11,12 +20,12 @@
 //
 // Example usage:
 //
-//	llmClient, err := anthropic.NewClient(apiKey, "claude-3-5-sonnet-20241022")
+//	llmClient, err := anthropic.NewClient(cfg.LLM.APIKey, logger)
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
 //
-//	client := llm.NewClient(llmClient, cfg.LLM, logger)
+//	client := llm.NewClient(llmClient, &cfg.LLM, logger)
 //
 //	resp, err := client.Complete(ctx, &llm.CompletionRequest{
 //	    SystemPrompt: "You are a graph planner...",
@@ -31,4 +40,23 @@
 //
 //	fmt.Println("Generated:", resp.Content)
 //	fmt.Println("Tokens used:", resp.TokensUsed)
+//
+// Streaming usage:
+//
+//	resp, err := client.CompleteStream(ctx, req, func(chunk llm.StreamChunk) error {
+//	    fmt.Print(chunk.Delta)
+//	    return nil // return an error to abort the stream
+//	})
+//
+// Replaying recorded calls:
+//
+//	cassette, err := llm.OpenCassette(config.CassetteConfig{
+//	    Mode: llm.CassetteReplay,
+//	    Path: "testdata/cassettes/planner.json",
+//	}, logger)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	client := llm.NewClient(cassette.Wrap(nil), &cfg.LLM, logger)
 package llm
@@ -0,0 +1,215 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorKind classifies an LLM failure.
+type ErrorKind string
+
+const (
+	// ErrorKindRateLimit indicates the provider rejected the request due to rate limits
+	ErrorKindRateLimit ErrorKind = "rate_limit"
+
+	// ErrorKindOverloaded indicates the provider is temporarily overloaded or unavailable
+	ErrorKindOverloaded ErrorKind = "overloaded"
+
+	// ErrorKindServer indicates an internal error on the provider side
+	ErrorKindServer ErrorKind = "server_error"
+
+	// ErrorKindNetwork indicates the provider could not be reached
+	ErrorKindNetwork ErrorKind = "network"
+
+	// ErrorKindTimeout indicates the request timed out
+	ErrorKindTimeout ErrorKind = "timeout"
+
+	// ErrorKindAuth indicates invalid or missing credentials
+	ErrorKindAuth ErrorKind = "auth"
+
+	// ErrorKindBadRequest indicates the request was rejected as malformed
+	ErrorKindBadRequest ErrorKind = "bad_request"
+
+	// ErrorKindCanceled indicates the caller cancelled the request
+	ErrorKindCanceled ErrorKind = "canceled"
+
+	// ErrorKindStreamInterrupted indicates a stream failed after output was delivered
+	ErrorKindStreamInterrupted ErrorKind = "stream_interrupted"
+
+	// ErrorKindUnknown indicates an error that could not be classified
+	ErrorKindUnknown ErrorKind = "unknown"
+)
+
+// Error is a classified LLM provider error.
+type Error struct {
+	// Kind classifies the failure
+	Kind ErrorKind
+
+	// StatusCode is the HTTP status returned by the provider, if any
+	StatusCode int
+
+	// Message is the provider-supplied error message
+	Message string
+
+	// RetryAfter is the delay requested by the provider before retrying
+	RetryAfter time.Duration
+
+	// Err is the underlying error, if any
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s (status %d): %s", e.Kind, e.StatusCode, msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the failure is transient.
+func (e *Error) Retryable() bool {
+	switch e.Kind {
+	case ErrorKindRateLimit, ErrorKindOverloaded, ErrorKindServer, ErrorKindNetwork, ErrorKindTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// fallbackAllowed reports whether the next backend of the fallback chain
+// may be tried after this failure.
+func (e *Error) fallbackAllowed() bool {
+	switch e.Kind {
+	case ErrorKindCanceled, ErrorKindStreamInterrupted:
+		return false
+	default:
+		return true
+	}
+}
+
+// tokensSpent reports whether the provider may have generated output before
+// the failure, so that the tokens reserved for the request count as used.
+// Rejected and unreachable requests cost nothing.
+func (e *Error) tokensSpent() bool {
+	switch e.Kind {
+	case ErrorKindRateLimit, ErrorKindOverloaded, ErrorKindServer, ErrorKindNetwork, ErrorKindAuth, ErrorKindBadRequest:
+		return false
+	default:
+		return true
+	}
+}
+
+// NewStatusError builds a classified error from an HTTP error response.
+func NewStatusError(statusCode int, message string, header http.Header) *Error {
+	return &Error{
+		Kind:       kindForStatus(statusCode),
+		StatusCode: statusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(header),
+	}
+}
+
+// ClassifyError returns the classified form of err. Errors already
+// classified by the provider layer are returned as is; others are
+// classified from context and network errors.
+func ClassifyError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var llmErr *Error
+	if errors.As(err, &llmErr) {
+		return llmErr
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return &Error{Kind: ErrorKindCanceled, Err: err}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Kind: ErrorKindTimeout, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return &Error{Kind: ErrorKindTimeout, Err: err}
+		}
+		return &Error{Kind: ErrorKindNetwork, Err: err}
+	}
+
+	return &Error{Kind: ErrorKindUnknown, Err: err}
+}
+
+// IsRetryable reports whether err is a transient LLM failure.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	return ClassifyError(err).Retryable()
+}
+
+// kindForStatus maps an HTTP status code to an error kind.
+func kindForStatus(statusCode int) ErrorKind {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorKindRateLimit
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrorKindAuth
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusGatewayTimeout:
+		return ErrorKindTimeout
+	case statusCode == http.StatusServiceUnavailable, statusCode == 529: // 529: Anthropic "overloaded"
+		return ErrorKindOverloaded
+	case statusCode >= 500:
+		return ErrorKindServer
+	case statusCode >= 400:
+		return ErrorKindBadRequest
+	default:
+		return ErrorKindUnknown
+	}
+}
+
+// parseRetryAfter reads the provider-requested retry delay from response headers.
+// It supports "retry-after-ms" and "Retry-After" in seconds or HTTP-date form.
+func parseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	if v := header.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
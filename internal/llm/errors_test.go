@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewStatusError(t *testing.T) {
+	tests := []struct {
+		status        int
+		wantKind      ErrorKind
+		wantRetryable bool
+	}{
+		{status: http.StatusTooManyRequests, wantKind: ErrorKindRateLimit, wantRetryable: true},
+		{status: http.StatusUnauthorized, wantKind: ErrorKindAuth},
+		{status: http.StatusForbidden, wantKind: ErrorKindAuth},
+		{status: http.StatusRequestTimeout, wantKind: ErrorKindTimeout, wantRetryable: true},
+		{status: http.StatusGatewayTimeout, wantKind: ErrorKindTimeout, wantRetryable: true},
+		{status: http.StatusServiceUnavailable, wantKind: ErrorKindOverloaded, wantRetryable: true},
+		{status: 529, wantKind: ErrorKindOverloaded, wantRetryable: true},
+		{status: http.StatusInternalServerError, wantKind: ErrorKindServer, wantRetryable: true},
+		{status: http.StatusBadGateway, wantKind: ErrorKindServer, wantRetryable: true},
+		{status: http.StatusBadRequest, wantKind: ErrorKindBadRequest},
+		{status: http.StatusNotFound, wantKind: ErrorKindBadRequest},
+		{status: http.StatusFound, wantKind: ErrorKindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			err := NewStatusError(tt.status, "failed", nil)
+			if err.Kind != tt.wantKind {
+				t.Errorf("Kind = %q, want %q", err.Kind, tt.wantKind)
+			}
+			if err.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", err.StatusCode, tt.status)
+			}
+			if got := IsRetryable(err); got != tt.wantRetryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	statusErr := NewStatusError(http.StatusTooManyRequests, "slow down", nil)
+
+	tests := []struct {
+		name     string
+		err      error
+		wantKind ErrorKind
+	}{
+		{name: "classified", err: statusErr, wantKind: ErrorKindRateLimit},
+		{name: "wrapped classified", err: fmt.Errorf("attempt 1: %w", statusErr), wantKind: ErrorKindRateLimit},
+		{name: "canceled", err: fmt.Errorf("request: %w", context.Canceled), wantKind: ErrorKindCanceled},
+		{name: "deadline", err: context.DeadlineExceeded, wantKind: ErrorKindTimeout},
+		{name: "network timeout", err: &net.OpError{Op: "dial", Err: timeoutError{}}, wantKind: ErrorKindTimeout},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, wantKind: ErrorKindNetwork},
+		{name: "unknown", err: errors.New("boom"), wantKind: ErrorKindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyError(tt.err)
+			if got.Kind != tt.wantKind {
+				t.Errorf("ClassifyError() kind = %q, want %q", got.Kind, tt.wantKind)
+			}
+			if !errors.Is(got, tt.err) && !errors.Is(tt.err, got) {
+				t.Errorf("ClassifyError() = %v, want it to keep %v", got, tt.err)
+			}
+		})
+	}
+
+	if ClassifyError(nil) != nil {
+		t.Error("ClassifyError(nil) != nil")
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "no header", header: nil, want: 0},
+		{name: "milliseconds", header: http.Header{"Retry-After-Ms": {"1500"}}, want: 1500 * time.Millisecond},
+		{name: "fractional milliseconds", header: http.Header{"Retry-After-Ms": {"2.5"}}, want: 2500 * time.Microsecond},
+		{
+			name:   "milliseconds take precedence",
+			header: http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"3"}},
+			want:   250 * time.Millisecond,
+		},
+		{
+			name:   "invalid milliseconds fall back to seconds",
+			header: http.Header{"Retry-After-Ms": {"soon"}, "Retry-After": {"3"}},
+			want:   3 * time.Second,
+		},
+		{name: "seconds", header: http.Header{"Retry-After": {"20"}}, want: 20 * time.Second},
+		{name: "fractional seconds", header: http.Header{"Retry-After": {"0.5"}}, want: 500 * time.Millisecond},
+		{name: "zero", header: http.Header{"Retry-After": {"0"}}, want: 0},
+		{name: "negative", header: http.Header{"Retry-After": {"-5"}}, want: 0},
+		{
+			name:   "past HTTP-date",
+			header: http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}},
+			want:   0,
+		},
+		{name: "garbage", header: http.Header{"Retry-After": {"later"}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header); got != tt.want {
+				t.Errorf("parseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfterHTTPDate(t *testing.T) {
+	header := http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}
+
+	// HTTP-dates have a resolution of one second
+	if got := parseRetryAfter(header); got <= 58*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter() = %v, want about a minute", got)
+	}
+}
@@ -0,0 +1,369 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// FakeFault is a failure injected by the fake provider.
+type FakeFault string
+
+const (
+	// FakeFaultTimeout blocks until the request timeout or context deadline
+	FakeFaultTimeout FakeFault = "timeout"
+
+	// FakeFaultRateLimit fails with a 429 rate limit error
+	FakeFaultRateLimit FakeFault = "rate_limit"
+
+	// FakeFaultOverloaded fails with a 529 overloaded error
+	FakeFaultOverloaded FakeFault = "overloaded"
+
+	// FakeFaultServerError fails with a 500 server error
+	FakeFaultServerError FakeFault = "server_error"
+
+	// FakeFaultTruncated returns the first half of the content with finish reason "length"
+	FakeFaultTruncated FakeFault = "truncated"
+
+	// FakeFaultMalformedJSON returns the content with a trailing comma before its last brace
+	FakeFaultMalformedJSON FakeFault = "malformed_json"
+
+	// FakeFaultProse returns a prose answer without any JSON
+	FakeFaultProse FakeFault = "prose"
+)
+
+// fakeProse is the content returned by FakeFaultProse.
+const fakeProse = "I have carefully considered the task. The best approach is to break it " +
+	"into a few steps and handle each of them in turn, routing on the results where needed."
+
+// FakeConfig contains the settings for the fake provider.
+type FakeConfig struct {
+	// ScriptPath is the YAML or JSON script of canned responses
+	ScriptPath string
+
+	// Model is reported when neither the script nor the request sets one
+	Model string
+
+	// Timeout bounds FakeFaultTimeout; zero waits for the context
+	Timeout time.Duration
+}
+
+// FakeScript is a script of canned responses for the fake provider.
+type FakeScript struct {
+	// Model is reported in responses, overriding the requested model
+	Model string `yaml:"model"`
+
+	// Responses are matched in order against the request messages
+	Responses []*FakeResponse `yaml:"responses"`
+
+	// Default is used when no response matches; without it the request fails
+	Default *FakeResponse `yaml:"default"`
+
+	// TextOnly disables structured output, as for providers without native support
+	TextOnly bool `yaml:"text_only"`
+}
+
+// FakeResponse is one scripted response.
+type FakeResponse struct {
+	// Match is a regular expression matched against the request messages;
+	// empty matches every request
+	Match string `yaml:"match"`
+
+	// Times limits how often the response is used; zero means unlimited
+	Times int `yaml:"times"`
+
+	// Content is the completion text
+	Content string `yaml:"content"`
+
+	// FinishReason is reported with the completion (default: "stop")
+	FinishReason string `yaml:"finish_reason"`
+
+	// Latency delays the response
+	Latency time.Duration `yaml:"latency"`
+
+	// Fault is injected instead of, or applied to, the response
+	Fault FakeFault `yaml:"fault"`
+
+	// RetryAfter is attached to rate limit and overloaded faults
+	RetryAfter time.Duration `yaml:"retry_after"`
+
+	pattern *regexp.Regexp
+	used    int
+}
+
+// FakeClient implements ports.LLMClient with scripted responses, for local
+// development and for exercising retries, extraction and repair offline.
+type FakeClient struct {
+	script  *FakeScript
+	model   string
+	timeout time.Duration
+	logger  *zap.Logger
+
+	mu sync.Mutex
+}
+
+// NewFakeClient creates a fake provider from the script at cfg.ScriptPath.
+func NewFakeClient(cfg FakeConfig, logger *zap.Logger) (*FakeClient, error) {
+	if cfg.ScriptPath == "" {
+		return nil, fmt.Errorf("script path is required")
+	}
+
+	script, err := LoadFakeScript(cfg.ScriptPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FakeClient{
+		script:  script,
+		model:   cfg.Model,
+		timeout: cfg.Timeout,
+		logger:  logger,
+	}, nil
+}
+
+// LoadFakeScript reads and compiles a fake provider script.
+// JSON scripts are accepted as they are valid YAML.
+func LoadFakeScript(path string) (*FakeScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake script: %w", err)
+	}
+
+	var script FakeScript
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse fake script: %w", err)
+	}
+
+	responses := script.Responses
+	if script.Default != nil {
+		responses = append(responses[:len(responses):len(responses)], script.Default)
+	}
+
+	for i, resp := range responses {
+		if resp.Match != "" {
+			pattern, err := regexp.Compile(resp.Match)
+			if err != nil {
+				return nil, fmt.Errorf("fake script response %d: invalid match: %w", i+1, err)
+			}
+			resp.pattern = pattern
+		}
+
+		switch resp.Fault {
+		case "", FakeFaultTimeout, FakeFaultRateLimit, FakeFaultOverloaded, FakeFaultServerError,
+			FakeFaultTruncated, FakeFaultMalformedJSON, FakeFaultProse:
+		default:
+			return nil, fmt.Errorf("fake script response %d: unknown fault: %s", i+1, resp.Fault)
+		}
+	}
+
+	return &script, nil
+}
+
+// Complete performs a standard text completion (ports.LLMClient interface).
+func (c *FakeClient) Complete(ctx context.Context, req ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	prompt := fakePrompt(req.Messages)
+
+	resp := c.match(prompt)
+	if resp == nil {
+		return nil, &Error{Kind: ErrorKindBadRequest, StatusCode: http.StatusBadRequest, Message: "no scripted response matches the request"}
+	}
+
+	c.logger.Debug("serving scripted completion",
+		zap.String("match", resp.Match),
+		zap.String("fault", string(resp.Fault)),
+	)
+
+	if resp.Latency > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ClassifyError(ctx.Err())
+		case <-time.After(resp.Latency):
+		}
+	}
+
+	switch resp.Fault {
+	case FakeFaultTimeout:
+		return nil, c.waitTimeout(ctx)
+	case FakeFaultRateLimit:
+		return nil, &Error{Kind: ErrorKindRateLimit, StatusCode: http.StatusTooManyRequests, Message: "scripted rate limit", RetryAfter: resp.RetryAfter}
+	case FakeFaultOverloaded:
+		return nil, &Error{Kind: ErrorKindOverloaded, StatusCode: 529, Message: "scripted overload", RetryAfter: resp.RetryAfter}
+	case FakeFaultServerError:
+		return nil, &Error{Kind: ErrorKindServer, StatusCode: http.StatusInternalServerError, Message: "scripted server error"}
+	}
+
+	content := resp.Content
+	finishReason := resp.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	switch resp.Fault {
+	case FakeFaultTruncated:
+		runes := []rune(content)
+		content = string(runes[:len(runes)/2])
+		finishReason = "length"
+	case FakeFaultMalformedJSON:
+		content = malformJSON(content)
+	case FakeFaultProse:
+		content = fakeProse
+	}
+
+	model := c.script.Model
+	if model == "" {
+		model = req.Model
+	}
+	if model == "" {
+		model = c.model
+	}
+
+	promptTokens := EstimateTokens(prompt)
+	completionTokens := EstimateTokens(content)
+
+	return &ports.CompletionResponse{
+		ID:    fmt.Sprintf("fake-%d", time.Now().UnixNano()),
+		Model: model,
+		Message: ports.Message{
+			Role:    "assistant",
+			Content: content,
+		},
+		FinishReason: finishReason,
+		Usage: ports.UsageInfo{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// match returns the first scripted response matching prompt that has uses
+// left, falling back to the default response.
+func (c *FakeClient) match(prompt string) *FakeResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, resp := range c.script.Responses {
+		if resp.Times > 0 && resp.used >= resp.Times {
+			continue
+		}
+		if resp.pattern != nil && !resp.pattern.MatchString(prompt) {
+			continue
+		}
+		resp.used++
+		return resp
+	}
+
+	return c.script.Default
+}
+
+// waitTimeout blocks until the configured timeout or the context ends.
+func (c *FakeClient) waitTimeout(ctx context.Context) error {
+	if c.timeout <= 0 {
+		<-ctx.Done()
+		return ClassifyError(ctx.Err())
+	}
+
+	select {
+	case <-ctx.Done():
+		return ClassifyError(ctx.Err())
+	case <-time.After(c.timeout):
+		return &Error{Kind: ErrorKindTimeout, Message: "scripted timeout"}
+	}
+}
+
+// CompleteWithTools performs a completion with tool calling support (ports.LLMClient interface).
+func (c *FakeClient) CompleteWithTools(ctx context.Context, req ports.CompletionRequest, tools []ports.Tool) (*ports.CompletionResponse, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// CompleteStructured performs a completion with guaranteed JSON schema conformance (ports.LLMClient interface).
+// The scripted content is decoded as the structured data; the schema is not enforced,
+// so content faults surface as UnstructuredErrors, as with servers ignoring the response format.
+func (c *FakeClient) CompleteStructured(ctx context.Context, req ports.CompletionRequest, schema ports.JSONSchema) (*ports.StructuredResponse, error) {
+	resp, err := c.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.FinishReason == "length" {
+		return nil, &TruncatedError{Content: resp.Message.Content, Usage: resp.Usage}
+	}
+
+	data, err := decodeStructured(resp.Message.Content, resp.Usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ports.StructuredResponse{
+		Data:      data,
+		Usage:     resp.Usage,
+		CreatedAt: resp.CreatedAt,
+	}, nil
+}
+
+// SupportsStructuredOutput reports whether the script serves structured output (llm.StructuredLLMClient interface).
+func (c *FakeClient) SupportsStructuredOutput() bool {
+	return !c.script.TextOnly
+}
+
+// GenerateCompletion generates a completion using domain.LLMRequest (compatibility method).
+func (c *FakeClient) GenerateCompletion(ctx context.Context, req interface{}) (interface{}, error) {
+	llmReq, ok := req.(*domain.LLMRequest)
+	if !ok {
+		return nil, fmt.Errorf("invalid request type")
+	}
+
+	messages := []ports.Message{}
+	if llmReq.System != "" {
+		messages = append(messages, ports.Message{Role: "system", Content: llmReq.System})
+	}
+	for _, msg := range llmReq.Messages {
+		messages = append(messages, ports.Message{Role: msg.Role, Content: msg.Content})
+	}
+
+	resp, err := c.Complete(ctx, ports.CompletionRequest{
+		Model:    llmReq.Model,
+		Messages: messages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.LLMResponse{
+		Content: resp.Message.Content,
+		Model:   resp.Model,
+		Usage: domain.Usage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// fakePrompt joins the message contents that scripted patterns match against.
+func fakePrompt(messages []ports.Message) string {
+	parts := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		parts = append(parts, msg.Content)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// malformJSON inserts a trailing comma before the last closing brace of content.
+func malformJSON(content string) string {
+	i := strings.LastIndex(content, "}")
+	if i < 0 {
+		return content + "{"
+	}
+	return content[:i] + ",\n" + content[i:]
+}
@@ -1,6 +1,12 @@
 // Package llm provides LLM integration for the node planner.
 package llm
 
+import (
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+)
+
 // CompletionRequest represents a request to the LLM.
 type CompletionRequest struct {
 	// SystemPrompt is the system message
@@ -9,7 +15,14 @@ type CompletionRequest struct {
 	// UserPrompt is the user message
 	UserPrompt string
 
-	// MaxTokens is the maximum tokens to generate
+	// Messages are earlier conversation turns, sent between the system
+	// prompt and UserPrompt
+	Messages []ports.Message
+
+	// Model overrides the model of the primary backend; fallbacks keep their own
+	Model string
+
+	// MaxTokens is the maximum tokens to generate (0: the configured default)
 	MaxTokens int
 
 	// Temperature controls randomness (0.0-1.0)
@@ -17,6 +30,19 @@ type CompletionRequest struct {
 
 	// StopSequences are sequences that stop generation
 	StopSequences []string
+
+	// Timeout bounds each attempt of the request (0: the configured default)
+	Timeout time.Duration
+
+	// ResponseSchema constrains the output to JSON matching the schema on
+	// backends with native structured output; others receive a text request
+	ResponseSchema ports.JSONSchema
+
+	// Phase labels the planning phase issuing the call (e.g. "analysis", "generation")
+	Phase string
+
+	// Iteration is the refinement iteration within the phase, if any
+	Iteration int
 }
 
 // CompletionResponse represents a response from the LLM.
@@ -24,24 +50,55 @@ type CompletionResponse struct {
 	// Content is the generated text
 	Content string
 
+	// Provider is the provider of the backend that served the request
+	Provider string
+
 	// Model is the model that generated the response
 	Model string
 
 	// TokensUsed is the total tokens consumed
 	TokensUsed int
 
+	// InputTokens is the number of prompt tokens consumed
+	InputTokens int
+
+	// OutputTokens is the number of generated tokens
+	OutputTokens int
+
+	// Cost is the estimated cost in USD, or 0 if the model has no configured price
+	Cost float64
+
 	// FinishReason indicates why generation stopped
 	FinishReason string
 
+	// Structured reports that Content is JSON constrained by the request's
+	// ResponseSchema (incomplete if the response is truncated)
+	Structured bool
+
 	// Error contains error details if the request failed
 	Error error
 }
 
+// Truncated reports whether generation stopped at the output token limit.
+func (r *CompletionResponse) Truncated() bool {
+	return r.FinishReason == "length" || r.FinishReason == "max_tokens"
+}
+
 // UsageStats tracks token usage across multiple LLM calls.
+// It is not safe for concurrent use; Client guards its aggregate with a mutex.
 type UsageStats struct {
 	// TotalTokens is the total tokens used
 	TotalTokens int
 
+	// InputTokens is the total prompt tokens used
+	InputTokens int
+
+	// OutputTokens is the total generated tokens
+	OutputTokens int
+
+	// TotalCost is the total estimated cost in USD
+	TotalCost float64
+
 	// TotalCalls is the number of LLM calls made
 	TotalCalls int
 
@@ -53,9 +110,14 @@ type UsageStats struct {
 }
 
 // AddCall updates usage statistics with a new call.
-func (u *UsageStats) AddCall(tokensUsed int, success bool) {
+func (u *UsageStats) AddCall(resp *CompletionResponse, success bool) {
 	u.TotalCalls++
-	u.TotalTokens += tokensUsed
+	if resp != nil {
+		u.TotalTokens += resp.TokensUsed
+		u.InputTokens += resp.InputTokens
+		u.OutputTokens += resp.OutputTokens
+		u.TotalCost += resp.Cost
+	}
 
 	if success {
 		u.SuccessfulCalls++
@@ -0,0 +1,523 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultOpenAIBaseURL is the base URL of the hosted OpenAI API.
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+	// DefaultLocalBaseURL is the OpenAI-compatible endpoint of a local Ollama server.
+	DefaultLocalBaseURL = "http://localhost:11434/v1"
+)
+
+// OpenAIConfig contains the settings for an OpenAI-compatible provider.
+type OpenAIConfig struct {
+	// BaseURL is the API root, e.g. "https://api.openai.com/v1"
+	BaseURL string
+
+	// APIKey is the credential sent with each request; empty disables authentication
+	APIKey string
+
+	// AuthHeader is the header carrying APIKey. "Authorization" (the default)
+	// sends it as a bearer token; any other header receives the raw key.
+	AuthHeader string
+
+	// Model is used when a request does not specify one
+	Model string
+
+	// Timeout bounds a blocking request, response body included. For
+	// streams it bounds only the wait for the response headers, as the body
+	// keeps arriving for as long as the model generates; streams end with
+	// the caller's context instead
+	Timeout time.Duration
+
+	// StructuredOutput enables json_schema response formats; disable it for
+	// servers that do not support them
+	StructuredOutput bool
+}
+
+// OpenAIClient implements ports.LLMClient using the OpenAI Chat Completions protocol.
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	authHeader string
+	model      string
+	structured bool
+	timeout    time.Duration
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewOpenAIClient creates a new client for the hosted OpenAI API or any
+// compatible endpoint that requires an API key.
+func NewOpenAIClient(cfg OpenAIConfig, logger *zap.Logger) (*OpenAIClient, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultOpenAIBaseURL
+	}
+
+	return newOpenAIClient(cfg, logger), nil
+}
+
+// NewLocalClient creates a new client for a self-hosted OpenAI-compatible
+// server such as Ollama, vLLM or llama.cpp. The API key is optional.
+func NewLocalClient(cfg OpenAIConfig, logger *zap.Logger) (*OpenAIClient, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultLocalBaseURL
+	}
+
+	if _, err := url.ParseRequestURI(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	return newOpenAIClient(cfg, logger), nil
+}
+
+// newOpenAIClient builds the client shared by the openai and local providers.
+func newOpenAIClient(cfg OpenAIConfig, logger *zap.Logger) *OpenAIClient {
+	authHeader := cfg.AuthHeader
+	if authHeader == "" {
+		authHeader = "Authorization"
+	}
+
+	// http.Client.Timeout would also cut off stream bodies, so only the
+	// headers are bounded here and blocking requests get a deadline in post
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = cfg.Timeout
+
+	return &OpenAIClient{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:     cfg.APIKey,
+		authHeader: authHeader,
+		model:      cfg.Model,
+		structured: cfg.StructuredOutput,
+		timeout:    cfg.Timeout,
+		httpClient: &http.Client{
+			Transport: transport,
+		},
+		logger: logger,
+	}
+}
+
+// chatMessage is a message in the Chat Completions wire format.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Name    string `json:"name,omitempty"`
+}
+
+// chatCompletionRequest is the body of POST /chat/completions.
+type chatCompletionRequest struct {
+	Model            string          `json:"model"`
+	Messages         []chatMessage   `json:"messages"`
+	MaxTokens        int             `json:"max_tokens,omitempty"`
+	Temperature      float64         `json:"temperature"`
+	TopP             float64         `json:"top_p,omitempty"`
+	Stop             []string        `json:"stop,omitempty"`
+	PresencePenalty  float64         `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64         `json:"frequency_penalty,omitempty"`
+	User             string          `json:"user,omitempty"`
+	Stream           bool            `json:"stream,omitempty"`
+	StreamOptions    *streamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat   *responseFormat `json:"response_format,omitempty"`
+}
+
+// responseFormat constrains the output of a chat completion.
+type responseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *jsonSchemaSpec `json:"json_schema,omitempty"`
+}
+
+// jsonSchemaSpec is the schema of a "json_schema" response format.
+type jsonSchemaSpec struct {
+	Name   string           `json:"name"`
+	Schema ports.JSONSchema `json:"schema"`
+	Strict bool             `json:"strict"`
+}
+
+// streamOptions configures a streamed chat completion.
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatCompletionResponse is the body returned by POST /chat/completions.
+type chatCompletionResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Index        int         `json:"index"`
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// chatCompletionChunk is a server-sent event of a streamed chat completion.
+type chatCompletionChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// openAIErrorResponse is the error envelope returned by OpenAI-compatible APIs.
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+	} `json:"error"`
+}
+
+// Complete performs a standard text completion (ports.LLMClient interface).
+func (c *OpenAIClient) Complete(ctx context.Context, req ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	return c.complete(ctx, c.buildChatRequest(req))
+}
+
+// complete sends a chat completion request and converts the first choice.
+func (c *OpenAIClient) complete(ctx context.Context, chatReq chatCompletionRequest) (*ports.CompletionResponse, error) {
+	c.logger.Debug("sending chat completion request",
+		zap.String("model", chatReq.Model),
+		zap.Int("message_count", len(chatReq.Messages)),
+	)
+
+	var chatResp chatCompletionResponse
+	if err := c.post(ctx, "/chat/completions", chatReq, &chatResp); err != nil {
+		return nil, err
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("chat completion returned no choices")
+	}
+	choice := chatResp.Choices[0]
+
+	created := time.Now()
+	if chatResp.Created > 0 {
+		created = time.Unix(chatResp.Created, 0)
+	}
+
+	totalTokens := chatResp.Usage.TotalTokens
+	if totalTokens == 0 {
+		totalTokens = chatResp.Usage.PromptTokens + chatResp.Usage.CompletionTokens
+	}
+
+	return &ports.CompletionResponse{
+		ID:    chatResp.ID,
+		Model: chatResp.Model,
+		Message: ports.Message{
+			Role:    choice.Message.Role,
+			Content: choice.Message.Content,
+		},
+		FinishReason: choice.FinishReason,
+		Usage: ports.UsageInfo{
+			PromptTokens:     chatResp.Usage.PromptTokens,
+			CompletionTokens: chatResp.Usage.CompletionTokens,
+			TotalTokens:      totalTokens,
+		},
+		CreatedAt: created,
+	}, nil
+}
+
+// StreamComplete performs a streaming completion (llm.StreamingLLMClient interface).
+// Content deltas are sent as they arrive; the final chunk carries the usage
+// reported by the server when it supports stream_options.include_usage.
+// The client timeout bounds the wait for the first response only.
+func (c *OpenAIClient) StreamComplete(ctx context.Context, req ports.CompletionRequest) (<-chan StreamChunk, error) {
+	chatReq := c.buildChatRequest(req)
+	chatReq.Stream = true
+	chatReq.StreamOptions = &streamOptions{IncludeUsage: true}
+
+	c.logger.Debug("sending streaming chat completion request",
+		zap.String("model", chatReq.Model),
+		zap.Int("message_count", len(chatReq.Messages)),
+	)
+
+	httpResp, err := c.send(ctx, "/chat/completions", chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan StreamChunk)
+
+	go func() {
+		defer close(chunks)
+		defer func() { _ = httpResp.Body.Close() }()
+
+		send := func(chunk StreamChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		final := StreamChunk{Done: true, Model: chatReq.Model}
+
+		scanner := bufio.NewScanner(httpResp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				send(final)
+				return
+			}
+
+			var event chatCompletionChunk
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(StreamChunk{Err: fmt.Errorf("failed to decode stream event: %w", err)})
+				return
+			}
+
+			if event.Model != "" {
+				final.Model = event.Model
+			}
+			if event.Usage != nil {
+				final.Usage = ports.UsageInfo{
+					PromptTokens:     event.Usage.PromptTokens,
+					CompletionTokens: event.Usage.CompletionTokens,
+					TotalTokens:      event.Usage.TotalTokens,
+				}
+			}
+
+			for _, choice := range event.Choices {
+				if choice.FinishReason != "" {
+					final.FinishReason = choice.FinishReason
+				}
+				if choice.Delta.Content != "" && !send(StreamChunk{Delta: choice.Delta.Content}) {
+					return
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			send(StreamChunk{Err: ClassifyError(fmt.Errorf("failed to read stream: %w", err))})
+			return
+		}
+
+		// Some servers close the stream without the [DONE] sentinel
+		send(final)
+	}()
+
+	return chunks, nil
+}
+
+// buildChatRequest converts a provider-neutral request into the Chat Completions format.
+func (c *OpenAIClient) buildChatRequest(req ports.CompletionRequest) chatCompletionRequest {
+	model := req.Model
+	if model == "" {
+		model = c.model
+	}
+
+	messages := make([]chatMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		messages = append(messages, chatMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+			Name:    msg.Name,
+		})
+	}
+
+	return chatCompletionRequest{
+		Model:            model,
+		Messages:         messages,
+		MaxTokens:        req.MaxTokens,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		User:             req.User,
+	}
+}
+
+// CompleteWithTools performs a completion with tool calling support (ports.LLMClient interface).
+func (c *OpenAIClient) CompleteWithTools(ctx context.Context, req ports.CompletionRequest, tools []ports.Tool) (*ports.CompletionResponse, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// CompleteStructured performs a completion with guaranteed JSON schema conformance (ports.LLMClient interface).
+// The schema is sent as a non-strict json_schema response format named after its "title".
+// Content that is not a JSON object fails with an UnstructuredError carrying it.
+func (c *OpenAIClient) CompleteStructured(ctx context.Context, req ports.CompletionRequest, schema ports.JSONSchema) (*ports.StructuredResponse, error) {
+	name, _ := schema["title"].(string)
+	if name == "" {
+		name = "response"
+	}
+
+	chatReq := c.buildChatRequest(req)
+	chatReq.ResponseFormat = &responseFormat{
+		Type: "json_schema",
+		JSONSchema: &jsonSchemaSpec{
+			Name:   name,
+			Schema: schema,
+		},
+	}
+
+	resp, err := c.complete(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.FinishReason == "length" {
+		return nil, &TruncatedError{Content: resp.Message.Content, Usage: resp.Usage}
+	}
+
+	data, err := decodeStructured(resp.Message.Content, resp.Usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ports.StructuredResponse{
+		Data:      data,
+		Usage:     resp.Usage,
+		CreatedAt: resp.CreatedAt,
+	}, nil
+}
+
+// SupportsStructuredOutput reports whether json_schema response formats are enabled (llm.StructuredLLMClient interface).
+func (c *OpenAIClient) SupportsStructuredOutput() bool {
+	return c.structured
+}
+
+// GenerateCompletion generates a completion using domain.LLMRequest (compatibility method).
+func (c *OpenAIClient) GenerateCompletion(ctx context.Context, req interface{}) (interface{}, error) {
+	llmReq, ok := req.(*domain.LLMRequest)
+	if !ok {
+		return nil, fmt.Errorf("invalid request type")
+	}
+
+	messages := []ports.Message{}
+	if llmReq.System != "" {
+		messages = append(messages, ports.Message{Role: "system", Content: llmReq.System})
+	}
+	for _, msg := range llmReq.Messages {
+		messages = append(messages, ports.Message{Role: msg.Role, Content: msg.Content})
+	}
+
+	resp, err := c.Complete(ctx, ports.CompletionRequest{
+		Model:       llmReq.Model,
+		Messages:    messages,
+		MaxTokens:   llmReq.MaxTokens,
+		Temperature: llmReq.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.LLMResponse{
+		Content: resp.Message.Content,
+		Model:   resp.Model,
+		Usage: domain.Usage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// post sends a JSON request to the API and decodes the JSON response into
+// out, within the client timeout.
+func (c *OpenAIClient) post(ctx context.Context, path string, in, out any) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	httpResp, err := c.send(ctx, path, in)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return ClassifyError(fmt.Errorf("failed to read response: %w", err))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// send sends a JSON request to the API and returns the successful response.
+// The caller must close the response body. Error responses are returned as
+// classified errors.
+func (c *OpenAIClient) send(ctx context.Context, path string, in any) (*http.Response, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		if strings.EqualFold(c.authHeader, "Authorization") {
+			httpReq.Header.Set(c.authHeader, "Bearer "+c.apiKey)
+		} else {
+			httpReq.Header.Set(c.authHeader, c.apiKey)
+		}
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, ClassifyError(fmt.Errorf("request failed: %w", err))
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		defer func() { _ = httpResp.Body.Close() }()
+
+		respBody, _ := io.ReadAll(httpResp.Body)
+		message := string(respBody)
+		var apiErr openAIErrorResponse
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		return nil, NewStatusError(httpResp.StatusCode, message, httpResp.Header)
+	}
+
+	return httpResp, nil
+}
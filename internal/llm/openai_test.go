@@ -0,0 +1,550 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"go.uber.org/zap"
+)
+
+// openAIServer is an httptest stand-in for an OpenAI-compatible API. It
+// records the last request and answers with handler.
+type openAIServer struct {
+	*httptest.Server
+
+	header http.Header
+	body   chatCompletionRequest
+}
+
+func newOpenAIServer(t *testing.T, handler func(w http.ResponseWriter, req chatCompletionRequest)) *openAIServer {
+	t.Helper()
+
+	s := &openAIServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+		var req chatCompletionRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			t.Errorf("request body is not a chat completion request: %v", err)
+		}
+
+		s.header, s.body = r.Header.Clone(), req
+		handler(w, req)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// newTestClient returns a planner client whose only backend is an
+// OpenAIClient talking to srv.
+func newTestClient(t *testing.T, srv *openAIServer, cfg OpenAIConfig) *Client {
+	t.Helper()
+
+	cfg.BaseURL = srv.URL + "/v1"
+	if cfg.APIKey == "" {
+		cfg.APIKey = "test-key"
+	}
+	if cfg.Model == "" {
+		cfg.Model = "gpt-4o-mini"
+	}
+
+	provider, err := NewOpenAIClient(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOpenAIClient() error = %v", err)
+	}
+
+	return NewClient(provider, &config.LLMConfig{
+		Provider:         "openai",
+		Model:            cfg.Model,
+		MaxTokens:        1000,
+		RetryConfig:      config.RetryConfig{MaxAttempts: 1},
+		StructuredOutput: cfg.StructuredOutput,
+	}, zap.NewNop())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// completion returns a chat completion body with the given content and usage.
+func completion(content, finishReason string, prompt, completionTokens, total int) map[string]any {
+	return map[string]any{
+		"id":      "chatcmpl-1",
+		"model":   "gpt-4o-mini-2024-07-18",
+		"created": 1700000000,
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]any{"role": "assistant", "content": content},
+			"finish_reason": finishReason,
+		}},
+		"usage": map[string]any{
+			"prompt_tokens":     prompt,
+			"completion_tokens": completionTokens,
+			"total_tokens":      total,
+		},
+	}
+}
+
+func TestOpenAIClientRequest(t *testing.T) {
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		writeJSON(w, http.StatusOK, completion("hello", "stop", 12, 3, 15))
+	})
+	client := newTestClient(t, srv, OpenAIConfig{APIKey: "sk-test"})
+
+	_, err := client.Complete(context.Background(), &CompletionRequest{
+		SystemPrompt:  "You are a planner.",
+		UserPrompt:    "Plan it.",
+		Temperature:   0.3,
+		StopSequences: []string{"END"},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if got := srv.header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer sk-test")
+	}
+	if got := srv.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	body := srv.body
+	if body.Model != "gpt-4o-mini" {
+		t.Errorf("model = %q, want gpt-4o-mini", body.Model)
+	}
+	if body.MaxTokens != 1000 {
+		t.Errorf("max_tokens = %d, want 1000", body.MaxTokens)
+	}
+	if body.Temperature != 0.3 {
+		t.Errorf("temperature = %v, want 0.3", body.Temperature)
+	}
+	if len(body.Stop) != 1 || body.Stop[0] != "END" {
+		t.Errorf("stop = %v, want [END]", body.Stop)
+	}
+	if body.Stream || body.ResponseFormat != nil {
+		t.Errorf("plain request has stream = %v, response_format = %v", body.Stream, body.ResponseFormat)
+	}
+
+	want := []chatMessage{
+		{Role: "system", Content: "You are a planner."},
+		{Role: "user", Content: "Plan it."},
+	}
+	if len(body.Messages) != len(want) {
+		t.Fatalf("messages = %+v, want %+v", body.Messages, want)
+	}
+	for i := range want {
+		if body.Messages[i] != want[i] {
+			t.Errorf("messages[%d] = %+v, want %+v", i, body.Messages[i], want[i])
+		}
+	}
+}
+
+func TestOpenAIClientAuthHeader(t *testing.T) {
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		writeJSON(w, http.StatusOK, completion("hello", "stop", 1, 1, 2))
+	})
+	client := newTestClient(t, srv, OpenAIConfig{APIKey: "azure-key", AuthHeader: "api-key"})
+
+	if _, err := client.Complete(context.Background(), &CompletionRequest{UserPrompt: "hi"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if got := srv.header.Get("api-key"); got != "azure-key" {
+		t.Errorf("api-key = %q, want the raw key", got)
+	}
+	if got := srv.header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none", got)
+	}
+}
+
+func TestOpenAIClientUsage(t *testing.T) {
+	tests := []struct {
+		name                      string
+		prompt, completion, total int
+		wantTotal                 int
+	}{
+		{name: "total reported", prompt: 100, completion: 40, total: 140, wantTotal: 140},
+		{name: "total missing", prompt: 100, completion: 40, total: 0, wantTotal: 140},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+				writeJSON(w, http.StatusOK, completion("hello", "stop", tt.prompt, tt.completion, tt.total))
+			})
+			client := newTestClient(t, srv, OpenAIConfig{})
+
+			resp, err := client.Complete(context.Background(), &CompletionRequest{UserPrompt: "hi"})
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+
+			if resp.Content != "hello" {
+				t.Errorf("Content = %q, want hello", resp.Content)
+			}
+			if resp.Provider != "openai" || resp.Model != "gpt-4o-mini-2024-07-18" {
+				t.Errorf("Provider/Model = %s/%s, want openai/gpt-4o-mini-2024-07-18", resp.Provider, resp.Model)
+			}
+			if resp.FinishReason != "stop" {
+				t.Errorf("FinishReason = %q, want stop", resp.FinishReason)
+			}
+			if resp.InputTokens != tt.prompt || resp.OutputTokens != tt.completion {
+				t.Errorf("InputTokens/OutputTokens = %d/%d, want %d/%d",
+					resp.InputTokens, resp.OutputTokens, tt.prompt, tt.completion)
+			}
+			if resp.TokensUsed != tt.wantTotal {
+				t.Errorf("TokensUsed = %d, want %d", resp.TokensUsed, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestOpenAIClientErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		header         map[string]string
+		body           string
+		wantKind       ErrorKind
+		wantMessage    string
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:        "error envelope",
+			status:      http.StatusUnauthorized,
+			body:        `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			wantKind:    ErrorKindAuth,
+			wantMessage: "Incorrect API key provided",
+		},
+		{
+			name:           "rate limit with Retry-After",
+			status:         http.StatusTooManyRequests,
+			header:         map[string]string{"Retry-After": "7"},
+			body:           `{"error":{"message":"Rate limit reached","type":"requests","code":null}}`,
+			wantKind:       ErrorKindRateLimit,
+			wantMessage:    "Rate limit reached",
+			wantRetryAfter: 7 * time.Second,
+		},
+		{
+			name:           "retry-after-ms",
+			status:         http.StatusServiceUnavailable,
+			header:         map[string]string{"retry-after-ms": "250"},
+			body:           `{"error":{"message":"Overloaded"}}`,
+			wantKind:       ErrorKindOverloaded,
+			wantMessage:    "Overloaded",
+			wantRetryAfter: 250 * time.Millisecond,
+		},
+		{
+			name:        "body without envelope",
+			status:      http.StatusBadGateway,
+			body:        "upstream unavailable",
+			wantKind:    ErrorKindServer,
+			wantMessage: "upstream unavailable",
+		},
+		{
+			name:        "bad request",
+			status:      http.StatusBadRequest,
+			body:        `{"error":{"message":"Unsupported parameter: 'stop'","type":"invalid_request_error"}}`,
+			wantKind:    ErrorKindBadRequest,
+			wantMessage: "Unsupported parameter: 'stop'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+			client := newTestClient(t, srv, OpenAIConfig{})
+
+			_, err := client.Complete(context.Background(), &CompletionRequest{UserPrompt: "hi"})
+
+			var llmErr *Error
+			if !errors.As(err, &llmErr) {
+				t.Fatalf("Complete() error = %v, want *Error", err)
+			}
+			if llmErr.Kind != tt.wantKind {
+				t.Errorf("Kind = %s, want %s", llmErr.Kind, tt.wantKind)
+			}
+			if llmErr.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", llmErr.StatusCode, tt.status)
+			}
+			if llmErr.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", llmErr.Message, tt.wantMessage)
+			}
+			if llmErr.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("RetryAfter = %v, want %v", llmErr.RetryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestOpenAIClientStream(t *testing.T) {
+	events := []string{
+		`{"model":"gpt-4o-mini-2024-07-18","choices":[{"delta":{"role":"assistant","content":""}}]}`,
+		`{"model":"gpt-4o-mini-2024-07-18","choices":[{"delta":{"content":"Hel"}}]}`,
+		`{"model":"gpt-4o-mini-2024-07-18","choices":[{"delta":{"content":"lo"}}]}`,
+		`{"model":"gpt-4o-mini-2024-07-18","choices":[{"delta":{},"finish_reason":"stop"}]}`,
+		`{"model":"gpt-4o-mini-2024-07-18","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`,
+	}
+
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		_, _ = io.WriteString(w, ": keep-alive\n\ndata: [DONE]\n\n")
+	})
+	client := newTestClient(t, srv, OpenAIConfig{})
+
+	var deltas []string
+	var final StreamChunk
+	resp, err := client.CompleteStream(context.Background(), &CompletionRequest{UserPrompt: "hi"}, func(chunk StreamChunk) error {
+		if chunk.Done {
+			final = chunk
+		} else {
+			deltas = append(deltas, chunk.Delta)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	if !srv.body.Stream || srv.body.StreamOptions == nil || !srv.body.StreamOptions.IncludeUsage {
+		t.Errorf("request stream = %v, stream_options = %+v, want streaming with usage", srv.body.Stream, srv.body.StreamOptions)
+	}
+
+	if got := strings.Join(deltas, "|"); got != "Hel|lo" {
+		t.Errorf("deltas = %q, want %q", got, "Hel|lo")
+	}
+	if resp.Content != "Hello" {
+		t.Errorf("Content = %q, want Hello", resp.Content)
+	}
+	if resp.FinishReason != "stop" || final.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q (final chunk %q), want stop", resp.FinishReason, final.FinishReason)
+	}
+	if resp.TokensUsed != 11 || resp.InputTokens != 9 || resp.OutputTokens != 2 {
+		t.Errorf("usage = %d/%d/%d, want 11/9/2", resp.TokensUsed, resp.InputTokens, resp.OutputTokens)
+	}
+	if resp.Model != "gpt-4o-mini-2024-07-18" {
+		t.Errorf("Model = %q, want the model of the stream", resp.Model)
+	}
+}
+
+func TestOpenAIClientStreamError(t *testing.T) {
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		w.Header().Set("Retry-After", "3")
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{
+			"error": map[string]any{"message": "slow down"},
+		})
+	})
+	client := newTestClient(t, srv, OpenAIConfig{})
+
+	_, err := client.CompleteStream(context.Background(), &CompletionRequest{UserPrompt: "hi"}, func(StreamChunk) error {
+		t.Error("chunk delivered for a failed stream")
+		return nil
+	})
+
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Kind != ErrorKindRateLimit || llmErr.RetryAfter != 3*time.Second {
+		t.Fatalf("CompleteStream() error = %v, want a rate limit error with a 3s Retry-After", err)
+	}
+}
+
+func TestOpenAIClientStructured(t *testing.T) {
+	schema := ports.JSONSchema{
+		"title":    "graph",
+		"type":     "object",
+		"required": []any{"nodes"},
+	}
+
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		writeJSON(w, http.StatusOK, completion(`{"nodes":{}}`, "stop", 50, 5, 55))
+	})
+	client := newTestClient(t, srv, OpenAIConfig{StructuredOutput: true})
+
+	resp, err := client.Complete(context.Background(), &CompletionRequest{
+		UserPrompt:     "Plan it.",
+		ResponseSchema: schema,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	format := srv.body.ResponseFormat
+	if format == nil || format.Type != "json_schema" || format.JSONSchema == nil {
+		t.Fatalf("response_format = %+v, want json_schema", format)
+	}
+	if format.JSONSchema.Name != "graph" || format.JSONSchema.Strict {
+		t.Errorf("json_schema name = %q, strict = %v, want graph, false", format.JSONSchema.Name, format.JSONSchema.Strict)
+	}
+	if format.JSONSchema.Schema["type"] != "object" {
+		t.Errorf("json_schema schema = %v, want the request schema", format.JSONSchema.Schema)
+	}
+
+	if !resp.Structured {
+		t.Error("Structured = false, want true")
+	}
+	if resp.Content != `{"nodes":{}}` {
+		t.Errorf("Content = %q, want the structured data", resp.Content)
+	}
+	if resp.TokensUsed != 55 {
+		t.Errorf("TokensUsed = %d, want 55", resp.TokensUsed)
+	}
+}
+
+func TestOpenAIClientStructuredNotJSON(t *testing.T) {
+	content := "Here is the graph:\n```json\n{\"nodes\": {},}\n```"
+
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		writeJSON(w, http.StatusOK, completion(content, "stop", 50, 20, 70))
+	})
+	client := newTestClient(t, srv, OpenAIConfig{StructuredOutput: true})
+
+	resp, err := client.Complete(context.Background(), &CompletionRequest{
+		UserPrompt:     "Plan it.",
+		ResponseSchema: ports.JSONSchema{"type": "object"},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if resp.Structured {
+		t.Error("Structured = true, want the content handled as text")
+	}
+	if resp.Content != content {
+		t.Errorf("Content = %q, want %q", resp.Content, content)
+	}
+	if resp.TokensUsed != 70 {
+		t.Errorf("TokensUsed = %d, want 70", resp.TokensUsed)
+	}
+}
+
+func TestOpenAIClientStructuredTruncated(t *testing.T) {
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		writeJSON(w, http.StatusOK, completion(`{"nodes":{"a":`, "length", 50, 1000, 1050))
+	})
+	client := newTestClient(t, srv, OpenAIConfig{StructuredOutput: true})
+
+	resp, err := client.Complete(context.Background(), &CompletionRequest{
+		UserPrompt:     "Plan it.",
+		ResponseSchema: ports.JSONSchema{"type": "object"},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if srv.body.ResponseFormat == nil || srv.body.ResponseFormat.JSONSchema.Name != "response" {
+		t.Errorf("response_format = %+v, want json_schema named response", srv.body.ResponseFormat)
+	}
+	if !resp.Truncated() || resp.Content != `{"nodes":{"a":` {
+		t.Errorf("response = %q (finish %q), want the truncated content", resp.Content, resp.FinishReason)
+	}
+}
+
+func TestOpenAIClientStructuredDisabled(t *testing.T) {
+	srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		writeJSON(w, http.StatusOK, completion("{}", "stop", 1, 1, 2))
+	})
+	client := newTestClient(t, srv, OpenAIConfig{StructuredOutput: false})
+
+	resp, err := client.Complete(context.Background(), &CompletionRequest{
+		UserPrompt:     "Plan it.",
+		ResponseSchema: ports.JSONSchema{"type": "object"},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if srv.body.ResponseFormat != nil {
+		t.Errorf("response_format = %+v, want none", srv.body.ResponseFormat)
+	}
+	if resp.Structured {
+		t.Error("Structured = true, want false")
+	}
+}
+
+func TestOpenAIClientTimeout(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+
+	t.Run("stream outlasting the timeout", func(t *testing.T) {
+		srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			for _, delta := range []string{"a", "b", "c", "d"} {
+				fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", delta)
+				w.(http.Flusher).Flush()
+				time.Sleep(timeout / 2)
+			}
+			_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		})
+		client := newTestClient(t, srv, OpenAIConfig{Timeout: timeout})
+
+		resp, err := client.CompleteStream(context.Background(), &CompletionRequest{UserPrompt: "hi"}, func(StreamChunk) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("CompleteStream() error = %v", err)
+		}
+		if resp.Content != "abcd" {
+			t.Errorf("Content = %q, want the whole stream", resp.Content)
+		}
+	})
+
+	t.Run("stream headers late", func(t *testing.T) {
+		srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+			time.Sleep(2 * timeout)
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		})
+		client := newTestClient(t, srv, OpenAIConfig{Timeout: timeout})
+
+		_, err := client.CompleteStream(context.Background(), &CompletionRequest{UserPrompt: "hi"}, func(StreamChunk) error {
+			return nil
+		})
+		if kind := ClassifyError(err); kind == nil || kind.Kind != ErrorKindTimeout {
+			t.Errorf("CompleteStream() error = %v, want a timeout", err)
+		}
+	})
+
+	t.Run("blocking body late", func(t *testing.T) {
+		srv := newOpenAIServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(2 * timeout)
+			_ = json.NewEncoder(w).Encode(completion("late", "stop", 1, 1, 2))
+		})
+		client := newTestClient(t, srv, OpenAIConfig{Timeout: timeout})
+
+		_, err := client.Complete(context.Background(), &CompletionRequest{UserPrompt: "hi"})
+		if kind := ClassifyError(err); kind == nil || kind.Kind != ErrorKindTimeout {
+			t.Errorf("Complete() error = %v, want a timeout", err)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+// Pricing estimates the cost of LLM calls from a per-model price table.
+type Pricing struct {
+	table map[string]config.ModelPricing
+}
+
+// NewPricing creates a cost estimator for the given price table.
+func NewPricing(table map[string]config.ModelPricing) *Pricing {
+	return &Pricing{table: table}
+}
+
+// Cost returns the cost of a call to model with the given token counts,
+// and whether a price was found for the model. Models are matched exactly
+// first, then by the longest configured prefix so that dated variants
+// (e.g. "gpt-4o-2024-08-06") pick up the price of their base model.
+func (p *Pricing) Cost(model string, inputTokens, outputTokens int) (float64, bool) {
+	price, ok := p.lookup(model)
+	if !ok {
+		return 0, false
+	}
+
+	cost := float64(inputTokens)*price.InputPerMillion/1e6 +
+		float64(outputTokens)*price.OutputPerMillion/1e6
+	return cost, true
+}
+
+// lookup finds the price entry for model.
+func (p *Pricing) lookup(model string) (config.ModelPricing, bool) {
+	if model == "" || len(p.table) == 0 {
+		return config.ModelPricing{}, false
+	}
+
+	if price, ok := p.table[model]; ok {
+		return price, true
+	}
+
+	var best string
+	for name := range p.table {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return config.ModelPricing{}, false
+	}
+	return p.table[best], true
+}
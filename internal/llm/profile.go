@@ -0,0 +1,33 @@
+package llm
+
+import "time"
+
+// Profile holds the resolved model and generation parameters of a
+// planning phase.
+type Profile struct {
+	Model       string
+	MaxTokens   int
+	Temperature float64
+	Timeout     time.Duration
+}
+
+// Profile returns the parameters configured for phase (llm.profiles),
+// falling back to the top-level LLM settings.
+func (c *Client) Profile(phase string) Profile {
+	p := c.config.Profile(phase)
+
+	return Profile{
+		Model:       p.Model,
+		MaxTokens:   p.MaxTokens,
+		Temperature: *p.Temperature,
+		Timeout:     p.Timeout,
+	}
+}
+
+// Apply sets the profile parameters on req.
+func (p Profile) Apply(req *CompletionRequest) {
+	req.Model = p.Model
+	req.MaxTokens = p.MaxTokens
+	req.Temperature = p.Temperature
+	req.Timeout = p.Timeout
+}
@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+// ErrRateLimitWait is returned when a request would have to wait for the
+// client-side rate limit beyond its context deadline.
+var ErrRateLimitWait = errors.New("LLM rate limit wait exceeds the request deadline")
+
+// RateLimiter is a client-side limiter of requests and tokens per minute
+// for a single LLM backend.
+//
+// Both limits are token buckets holding one minute's worth of capacity and
+// refilled continuously, so a full minute's budget may be spent in a burst.
+// Token costs are estimated up front and unused tokens are given back once
+// the actual usage is known.
+type RateLimiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket
+	tokens   *tokenBucket
+	now      func() time.Time
+}
+
+// tokenBucket is a token bucket refilled continuously.
+type tokenBucket struct {
+	capacity float64
+	rate     float64 // tokens added per second
+	level    float64
+	last     time.Time
+}
+
+// NewRateLimiter creates a new rate limiter with the given configuration.
+// Limits left at zero are not enforced.
+func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{now: time.Now}
+	now := l.now()
+
+	if cfg.RequestsPerMinute > 0 {
+		l.requests = newTokenBucket(cfg.RequestsPerMinute, now)
+	}
+	if cfg.TokensPerMinute > 0 {
+		l.tokens = newTokenBucket(cfg.TokensPerMinute, now)
+	}
+
+	return l
+}
+
+// newTokenBucket creates a full bucket refilling perMinute tokens a minute.
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		level:    float64(perMinute),
+		last:     now,
+	}
+}
+
+// Wait blocks until one request with the given estimated token count is
+// allowed. It fails immediately with ErrRateLimitWait if the wait would
+// outlast the context deadline, and returns early if ctx is cancelled.
+// Requests larger than the token limit wait for a full bucket.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	if l.requests == nil && l.tokens == nil {
+		return nil
+	}
+
+	for {
+		wait := l.reserve(tokens)
+		if wait <= 0 {
+			return nil
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && l.now().Add(wait).After(deadline) {
+			return fmt.Errorf("%w: next slot in %s", ErrRateLimitWait, wait.Round(time.Millisecond))
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for LLM rate limit: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Refund gives back tokens reserved by Wait that were not used.
+func (l *RateLimiter) Refund(tokens int) {
+	if l.tokens == nil || tokens <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.tokens
+	b.refill(l.now())
+	b.level = math.Min(b.capacity, b.level+float64(tokens))
+}
+
+// reserve takes a request and tokens from the buckets if both have enough,
+// or returns how long to wait until they will.
+func (l *RateLimiter) reserve(tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+
+	if b := l.requests; b != nil {
+		b.refill(now)
+		wait = max(wait, b.waitFor(1))
+	}
+	if b := l.tokens; b != nil {
+		b.refill(now)
+		wait = max(wait, b.waitFor(math.Min(float64(tokens), b.capacity)))
+	}
+
+	if wait > 0 {
+		return wait
+	}
+
+	if l.requests != nil {
+		l.requests.level--
+	}
+	if l.tokens != nil {
+		l.tokens.level -= math.Min(float64(tokens), l.tokens.capacity)
+	}
+
+	return 0
+}
+
+// refill adds the tokens accrued since the last refill.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.level = math.Min(b.capacity, b.level+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// waitFor returns how long until the bucket holds n tokens.
+func (b *tokenBucket) waitFor(n float64) time.Duration {
+	if b.level >= n {
+		return 0
+	}
+	return time.Duration((n - b.level) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+// frozenLimiter returns a limiter whose clock stands still, so that its
+// buckets only change through reservations and refunds.
+func frozenLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	l := NewRateLimiter(cfg)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	return l
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := frozenLimiter(config.RateLimitConfig{RequestsPerMinute: 2, TokensPerMinute: 600})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := l.Wait(ctx, 400); err != nil {
+		t.Fatalf("Wait(400) error = %v", err)
+	}
+
+	// 200 tokens are left and refill at 10 a second
+	if err := l.Wait(ctx, 300); !errors.Is(err, ErrRateLimitWait) {
+		t.Fatalf("Wait(300) error = %v, want ErrRateLimitWait", err)
+	}
+
+	l.Refund(100)
+	if err := l.Wait(ctx, 300); err != nil {
+		t.Fatalf("Wait(300) after a refund error = %v", err)
+	}
+
+	// Both requests of the minute are spent
+	if err := l.Wait(ctx, 0); !errors.Is(err, ErrRateLimitWait) {
+		t.Fatalf("third Wait() error = %v, want ErrRateLimitWait", err)
+	}
+}
+
+func TestRateLimiterRefundCapped(t *testing.T) {
+	l := frozenLimiter(config.RateLimitConfig{TokensPerMinute: 600})
+
+	if err := l.Wait(context.Background(), 100); err != nil {
+		t.Fatalf("Wait(100) error = %v", err)
+	}
+	l.Refund(1000)
+
+	if l.tokens.level != 600 {
+		t.Errorf("level = %v, want the capacity of 600", l.tokens.level)
+	}
+}
+
+// TestClientRefundsRejectedAttempts drives a backend that rejects the first
+// attempts before succeeding. Only the tokens of the successful attempt stay
+// charged to the limiter.
+func TestClientRefundsRejectedAttempts(t *testing.T) {
+	for _, fault := range []FakeFault{FakeFaultRateLimit, FakeFaultOverloaded, FakeFaultServerError} {
+		t.Run(string(fault), func(t *testing.T) {
+			script := fmt.Sprintf(`
+responses:
+  - times: 2
+    fault: %s
+default:
+  content: '{"nodes": {}}'
+`, fault)
+			client := newFakeTestClient(t, script, nil)
+			client.retrier = NewRetrier(config.RetryConfig{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1})
+
+			limiter := frozenLimiter(config.RateLimitConfig{TokensPerMinute: 100000})
+			client.backends[0].limiter = limiter
+
+			resp, err := client.Complete(context.Background(), &CompletionRequest{UserPrompt: "Plan it.", MaxTokens: 5000})
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+
+			if want := float64(100000 - resp.TokensUsed); limiter.tokens.level != want {
+				t.Errorf("token bucket level = %v, want %v (only the %d tokens used charged)",
+					limiter.tokens.level, want, resp.TokensUsed)
+			}
+		})
+	}
+}
+
+func TestErrorTokensSpent(t *testing.T) {
+	tests := []struct {
+		kind ErrorKind
+		want bool
+	}{
+		{kind: ErrorKindRateLimit, want: false},
+		{kind: ErrorKindOverloaded, want: false},
+		{kind: ErrorKindServer, want: false},
+		{kind: ErrorKindNetwork, want: false},
+		{kind: ErrorKindAuth, want: false},
+		{kind: ErrorKindBadRequest, want: false},
+		{kind: ErrorKindTimeout, want: true},
+		{kind: ErrorKindCanceled, want: true},
+		{kind: ErrorKindStreamInterrupted, want: true},
+		{kind: ErrorKindUnknown, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := (&Error{Kind: tt.kind}).tokensSpent(); got != tt.want {
+			t.Errorf("tokensSpent() of %s = %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+}
@@ -3,17 +3,21 @@ package llm
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/aescanero/dago-node-planner/internal/config"
 )
 
-// Retrier provides retry logic with exponential backoff.
+// Retrier provides retry logic with exponential backoff and jitter.
+// Only transient errors (see IsRetryable) are retried, and a delay
+// requested by the provider takes precedence over the backoff schedule.
 type Retrier struct {
 	maxAttempts  int
 	initialDelay time.Duration
 	maxDelay     time.Duration
 	multiplier   float64
+	jitter       float64
 }
 
 // NewRetrier creates a new retrier with the given configuration.
@@ -23,6 +27,7 @@ func NewRetrier(cfg config.RetryConfig) *Retrier {
 		initialDelay: cfg.InitialDelay,
 		maxDelay:     cfg.MaxDelay,
 		multiplier:   cfg.Multiplier,
+		jitter:       cfg.Jitter,
 	}
 }
 
@@ -54,7 +59,7 @@ func (r *Retrier) Do(ctx context.Context, fn func() error) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(delay):
+		case <-time.After(r.waitFor(err, delay)):
 			// Calculate next delay with exponential backoff
 			delay = time.Duration(float64(delay) * r.multiplier)
 			if delay > r.maxDelay {
@@ -68,8 +73,26 @@ func (r *Retrier) Do(ctx context.Context, fn func() error) error {
 
 // shouldRetry determines if an error is retryable.
 func (r *Retrier) shouldRetry(err error) bool {
-	// For now, retry all errors
-	// In a production system, you'd check for specific error types
-	// (e.g., rate limits, temporary network errors)
-	return true
+	return IsRetryable(err)
+}
+
+// waitFor returns how long to wait before the next attempt.
+// A provider-supplied retry-after delay is honoured as is; otherwise
+// the scheduled backoff delay is randomised by the configured jitter.
+func (r *Retrier) waitFor(err error, delay time.Duration) time.Duration {
+	if retryAfter := ClassifyError(err).RetryAfter; retryAfter > 0 {
+		return retryAfter
+	}
+
+	if r.jitter <= 0 {
+		return delay
+	}
+
+	// Spread the delay uniformly over [delay*(1-jitter), delay*(1+jitter)]
+	factor := 1 + r.jitter*(2*rand.Float64()-1)
+	wait := time.Duration(float64(delay) * factor)
+	if wait > r.maxDelay {
+		wait = r.maxDelay
+	}
+	return wait
 }
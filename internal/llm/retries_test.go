@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+func TestRetrierDo(t *testing.T) {
+	// The backoff is far longer than the test timeout, so that only a
+	// provider-requested delay lets the retries finish in time.
+	retrier := NewRetrier(config.RetryConfig{
+		MaxAttempts:  3,
+		InitialDelay: time.Hour,
+		MaxDelay:     time.Hour,
+		Multiplier:   2,
+	})
+	retryAfter := NewStatusError(http.StatusTooManyRequests, "slow down", http.Header{"Retry-After-Ms": {"20"}})
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+		wantErr      bool
+		wantWait     time.Duration
+	}{
+		{
+			name:         "follows the requested delay",
+			errs:         []error{retryAfter, retryAfter, nil},
+			wantAttempts: 3,
+			wantWait:     40 * time.Millisecond,
+		},
+		{
+			name:         "gives up after the last attempt",
+			errs:         []error{retryAfter, retryAfter, retryAfter},
+			wantAttempts: 3,
+			wantErr:      true,
+			wantWait:     40 * time.Millisecond,
+		},
+		{
+			name:         "does not retry permanent errors",
+			errs:         []error{NewStatusError(http.StatusUnauthorized, "bad key", nil)},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			attempts := 0
+			start := time.Now()
+			err := retrier.Do(ctx, func() error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+			elapsed := time.Since(start)
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if last := tt.errs[len(tt.errs)-1]; (err != nil) != tt.wantErr || !errors.Is(err, last) {
+				t.Errorf("Do() error = %v, want %v", err, last)
+			}
+			if elapsed < tt.wantWait {
+				t.Errorf("Do() returned after %v, want at least %v of requested delays", elapsed, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestRetrierWaitFor(t *testing.T) {
+	retrier := NewRetrier(config.RetryConfig{MaxDelay: 10 * time.Second, Jitter: 0.5})
+
+	retryAfter := NewStatusError(http.StatusTooManyRequests, "slow down", http.Header{"Retry-After": {"30"}})
+	if got := retrier.waitFor(retryAfter, time.Second); got != 30*time.Second {
+		t.Errorf("waitFor() = %v, want the requested 30s even above the maximum delay", got)
+	}
+
+	for range 100 {
+		got := retrier.waitFor(errors.New("boom"), 2*time.Second)
+		if got < time.Second || got > 3*time.Second {
+			t.Fatalf("waitFor() = %v, want the 2s delay with 50%% jitter", got)
+		}
+	}
+}
+
+func TestRetrierCanceled(t *testing.T) {
+	retrier := NewRetrier(config.RetryConfig{MaxAttempts: 3, InitialDelay: time.Hour, MaxDelay: time.Hour, Multiplier: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := retrier.Do(ctx, func() error {
+		cancel()
+		return NewStatusError(http.StatusServiceUnavailable, "busy", nil)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"go.uber.org/zap"
+)
+
+// StreamChunk is an incremental piece of a streamed completion.
+type StreamChunk struct {
+	// Delta is the content generated since the previous chunk
+	Delta string
+
+	// Done marks the final chunk, which carries the fields below
+	Done bool
+
+	// Model is the model that generated the completion
+	Model string
+
+	// FinishReason indicates why generation stopped
+	FinishReason string
+
+	// Usage is the token usage of the whole completion
+	Usage ports.UsageInfo
+
+	// Err reports a failure of the stream; it is always the last chunk sent
+	Err error
+}
+
+// StreamingLLMClient is implemented by providers that support streaming
+// completions. The returned channel is closed after the final chunk.
+type StreamingLLMClient interface {
+	StreamComplete(ctx context.Context, req ports.CompletionRequest) (<-chan StreamChunk, error)
+}
+
+// StreamFunc receives the chunks of a streamed completion. Returning an
+// error aborts the stream; the error is returned by CompleteStream.
+type StreamFunc func(chunk StreamChunk) error
+
+// CompleteStream sends a completion request and passes content deltas to
+// onChunk as they arrive, followed by a final chunk with usage and finish
+// reason. Providers without streaming support, and structured requests,
+// yield a single chunk.
+//
+// Retries and fallbacks only happen before the first delta is delivered;
+// a stream that fails afterwards returns an ErrorKindStreamInterrupted error.
+// The assembled response is returned once the stream completes.
+func (c *Client) CompleteStream(ctx context.Context, req *CompletionRequest, onChunk StreamFunc) (*CompletionResponse, error) {
+	c.logger.Debug("sending LLM streaming request",
+		zap.Int("max_tokens", req.MaxTokens),
+		zap.Float64("temperature", req.Temperature),
+	)
+
+	return c.execute(ctx, req, func(ctx context.Context, b *backend) (*CompletionResponse, error) {
+		return c.doStream(ctx, b, req, onChunk)
+	})
+}
+
+// doStream performs a single streaming request against a backend without retry.
+func (c *Client) doStream(ctx context.Context, b *backend, req *CompletionRequest, onChunk StreamFunc) (*CompletionResponse, error) {
+	streamer, ok := b.llmClient.(StreamingLLMClient)
+	if !ok || c.structured(b, req) {
+		// Fall back to a blocking call delivered as a single chunk
+		resp, err := c.doComplete(ctx, b, req)
+		if err != nil {
+			return nil, err
+		}
+		if err := onChunk(StreamChunk{Delta: resp.Content}); err != nil {
+			return nil, &Error{Kind: ErrorKindCanceled, Message: "stream aborted by caller", Err: err}
+		}
+		if err := onChunk(c.finalChunk(resp)); err != nil {
+			return nil, &Error{Kind: ErrorKindCanceled, Message: "stream aborted by caller", Err: err}
+		}
+		return resp, nil
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks, err := streamer.StreamComplete(streamCtx, c.buildRequest(b, req))
+	if err != nil {
+		return nil, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	var content strings.Builder
+	var final StreamChunk
+	delivered := false
+
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			if delivered {
+				return nil, &Error{Kind: ErrorKindStreamInterrupted, Message: "stream failed after output was delivered", Err: chunk.Err}
+			}
+			return nil, fmt.Errorf("LLM request failed: %w", chunk.Err)
+		}
+
+		if chunk.Done {
+			final = chunk
+			break
+		}
+
+		if chunk.Delta == "" {
+			continue
+		}
+
+		content.WriteString(chunk.Delta)
+		delivered = true
+
+		if err := onChunk(StreamChunk{Delta: chunk.Delta}); err != nil {
+			return nil, &Error{Kind: ErrorKindCanceled, Message: "stream aborted by caller", Err: err}
+		}
+	}
+
+	if !final.Done {
+		err := fmt.Errorf("stream ended without a final chunk")
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if delivered {
+			return nil, &Error{Kind: ErrorKindStreamInterrupted, Message: "stream failed after output was delivered", Err: err}
+		}
+		return nil, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	resp := c.buildResponse(b, req, content.String(), final.Model, final.FinishReason, final.Usage)
+	if err := onChunk(c.finalChunk(resp)); err != nil {
+		return nil, &Error{Kind: ErrorKindCanceled, Message: "stream aborted by caller", Err: err}
+	}
+
+	return resp, nil
+}
+
+// finalChunk builds the closing chunk of a stream from the assembled response.
+func (c *Client) finalChunk(resp *CompletionResponse) StreamChunk {
+	return StreamChunk{
+		Done:         true,
+		Model:        resp.Model,
+		FinishReason: resp.FinishReason,
+		Usage: ports.UsageInfo{
+			PromptTokens:     resp.InputTokens,
+			CompletionTokens: resp.OutputTokens,
+			TotalTokens:      resp.TokensUsed,
+		},
+	}
+}
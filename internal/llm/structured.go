@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"go.uber.org/zap"
+)
+
+// StructuredLLMClient is implemented by providers that may support native
+// structured output through CompleteStructured. Providers that do not
+// implement it only receive plain text requests.
+type StructuredLLMClient interface {
+	SupportsStructuredOutput() bool
+}
+
+// TruncatedError is returned by structured completions whose output hit the
+// token limit. It carries the partial output so that it can be continued
+// as text.
+type TruncatedError struct {
+	// Content is the partial output
+	Content string
+
+	// Usage is the token usage of the call
+	Usage ports.UsageInfo
+}
+
+// Error implements the error interface.
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("structured response truncated at %d tokens", e.Usage.CompletionTokens)
+}
+
+// UnstructuredError is returned by structured completions whose output is
+// not a JSON object, as local servers may produce when they ignore the
+// response format. It carries the output so that it can be extracted as text.
+type UnstructuredError struct {
+	// Content is the output of the call
+	Content string
+
+	// Usage is the token usage of the call
+	Usage ports.UsageInfo
+
+	// Err is the decoding error
+	Err error
+}
+
+// Error implements the error interface.
+func (e *UnstructuredError) Error() string {
+	return fmt.Sprintf("structured response is not a JSON object: %v", e.Err)
+}
+
+// Unwrap returns the decoding error.
+func (e *UnstructuredError) Unwrap() error {
+	return e.Err
+}
+
+// decodeStructured decodes the output of a structured completion, failing
+// with an UnstructuredError if it is not a JSON object.
+func decodeStructured(content string, usage ports.UsageInfo) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &data); err != nil {
+		return nil, &UnstructuredError{Content: content, Usage: usage, Err: err}
+	}
+	if data == nil {
+		return nil, &UnstructuredError{Content: content, Usage: usage, Err: fmt.Errorf("got null")}
+	}
+	return data, nil
+}
+
+// structured reports whether req should be sent to b as a structured request.
+func (c *Client) structured(b *backend, req *CompletionRequest) bool {
+	if req.ResponseSchema == nil {
+		return false
+	}
+	s, ok := b.llmClient.(StructuredLLMClient)
+	return ok && s.SupportsStructuredOutput()
+}
+
+// doStructured performs a single structured completion against a backend
+// without retry. The structured data is returned re-encoded as JSON content.
+// Truncated output is returned as is with finish reason "length" so that it
+// can be continued, and output that is not JSON as a text response so that
+// it can be extracted and repaired like any other.
+func (c *Client) doStructured(ctx context.Context, b *backend, req *CompletionRequest) (*CompletionResponse, error) {
+	llmResp, err := b.llmClient.CompleteStructured(ctx, c.buildRequest(b, req), req.ResponseSchema)
+	var truncated *TruncatedError
+	if errors.As(err, &truncated) {
+		resp := c.buildResponse(b, req, truncated.Content, "", "length", truncated.Usage)
+		resp.Structured = true
+		return resp, nil
+	}
+	var unstructured *UnstructuredError
+	if errors.As(err, &unstructured) {
+		c.logger.Warn("structured response is not JSON, handling it as text",
+			zap.String("provider", b.provider),
+			zap.Error(unstructured.Err),
+		)
+		return c.buildResponse(b, req, unstructured.Content, "", "stop", unstructured.Usage), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	content, err := json.Marshal(llmResp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode structured response: %w", err)
+	}
+
+	resp := c.buildResponse(b, req, string(content), "", "stop", llmResp.Usage)
+	resp.Structured = true
+
+	return resp, nil
+}
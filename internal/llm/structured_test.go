@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"go.uber.org/zap"
+)
+
+// newFakeTestClient returns a planner client backed by the fake provider
+// serving script, wrapped by cassette if it is not nil.
+func newFakeTestClient(t *testing.T, script string, cassette *Cassette) *Client {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var provider ports.LLMClient
+	provider, err := NewFakeClient(FakeConfig{ScriptPath: path}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewFakeClient() error = %v", err)
+	}
+	if cassette != nil {
+		provider = cassette.Wrap(provider)
+	}
+
+	return NewClient(provider, &config.LLMConfig{
+		Provider:         "fake",
+		Model:            "fake-model",
+		MaxTokens:        1000,
+		RetryConfig:      config.RetryConfig{MaxAttempts: 1},
+		StructuredOutput: true,
+	}, zap.NewNop())
+}
+
+func TestStructuredMalformedJSON(t *testing.T) {
+	const script = `
+default:
+  fault: malformed_json
+  content: '{"nodes": {"a": {}}}'
+`
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	req := &CompletionRequest{
+		UserPrompt:     "Plan it.",
+		ResponseSchema: ports.JSONSchema{"type": "object"},
+	}
+
+	recorder, err := OpenCassette(config.CassetteConfig{Mode: CassetteRecord, Path: cassettePath}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("OpenCassette() error = %v", err)
+	}
+	recorded, err := newFakeTestClient(t, script, recorder).Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	player, err := OpenCassette(config.CassetteConfig{Mode: CassetteReplay, Path: cassettePath}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("OpenCassette() error = %v", err)
+	}
+	replayed, err := NewClient(player.Wrap(nil), &config.LLMConfig{
+		Provider:         "fake",
+		Model:            "fake-model",
+		MaxTokens:        1000,
+		RetryConfig:      config.RetryConfig{MaxAttempts: 1},
+		StructuredOutput: true,
+	}, zap.NewNop()).Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("replayed Complete() error = %v", err)
+	}
+
+	for name, resp := range map[string]*CompletionResponse{"recorded": recorded, "replayed": replayed} {
+		if resp.Structured {
+			t.Errorf("%s: Structured = true, want the malformed content handled as text", name)
+		}
+		if want := malformJSON(`{"nodes": {"a": {}}}`); resp.Content != want {
+			t.Errorf("%s: Content = %q, want %q", name, resp.Content, want)
+		}
+		if resp.TokensUsed == 0 {
+			t.Errorf("%s: TokensUsed = 0, want the usage of the call", name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package llm
+
+import "unicode/utf8"
+
+// charsPerToken is the average number of characters per token for English
+// text and JSON with the tokenizers of the supported providers.
+const charsPerToken = 4
+
+// EstimateTokens returns a rough estimate of the number of tokens in text.
+func EstimateTokens(text string) int {
+	n := utf8.RuneCountInString(text)
+	return (n + charsPerToken - 1) / charsPerToken
+}
+
+// messageOverhead is the estimated token cost of the framing of each message.
+const messageOverhead = 4
+
+// TruncateToTokens cuts text to about maxTokens tokens.
+func TruncateToTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if EstimateTokens(text) <= maxTokens {
+		return text
+	}
+
+	runes := []rune(text)
+	return string(runes[:maxTokens*charsPerToken])
+}
+
+// estimatePromptTokens returns a rough estimate of the input tokens of req.
+func estimatePromptTokens(req *CompletionRequest) int {
+	return estimateContextTokens(req) + EstimateTokens(req.UserPrompt) + messageOverhead
+}
+
+// estimateContextTokens estimates the input tokens of req other than the
+// user prompt: the system prompt and earlier turns.
+func estimateContextTokens(req *CompletionRequest) int {
+	tokens := 0
+	if req.SystemPrompt != "" {
+		tokens += EstimateTokens(req.SystemPrompt) + messageOverhead
+	}
+	for _, msg := range req.Messages {
+		tokens += EstimateTokens(msg.Content) + messageOverhead
+	}
+	return tokens
+}
@@ -0,0 +1,199 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBudgetExhausted is returned when the next LLM call of a plan would
+// exceed the plan's token or cost budget. The call is not sent.
+var ErrBudgetExhausted = errors.New("LLM budget exhausted")
+
+// Budget limits the LLM spend of a single plan. Zero values mean unlimited.
+type Budget struct {
+	// MaxTokens caps the total tokens (input and output) of the plan
+	MaxTokens int
+
+	// MaxCost caps the estimated cost of the plan in USD
+	MaxCost float64
+}
+
+// IsZero reports whether the budget is unlimited.
+func (b Budget) IsZero() bool {
+	return b.MaxTokens <= 0 && b.MaxCost <= 0
+}
+
+// PhaseUsage is the token usage of one planning phase (or one iteration of it).
+type PhaseUsage struct {
+	// Phase is the planning phase (e.g. "analysis", "generation")
+	Phase string
+
+	// Iteration is the refinement iteration, or 0 for single-shot phases
+	Iteration int
+
+	// Calls is the number of LLM calls made
+	Calls int
+
+	// FailedCalls is the number of calls that failed
+	FailedCalls int
+
+	// InputTokens is the number of prompt tokens consumed
+	InputTokens int
+
+	// OutputTokens is the number of generated tokens
+	OutputTokens int
+
+	// TotalTokens is the total tokens consumed
+	TotalTokens int
+
+	// Cost is the estimated cost in USD
+	Cost float64
+}
+
+// PlanUsage accumulates the usage of every LLM call made for a single plan.
+// It is safe for concurrent use.
+//
+// Calls in flight hold a reservation of their estimated usage against the
+// budget, so that concurrent calls cannot all pass the budget check before
+// any of them is recorded.
+type PlanUsage struct {
+	mu     sync.Mutex
+	totals UsageStats
+	phases []*PhaseUsage
+	budget Budget
+
+	reservedTokens int
+	reservedCost   float64
+}
+
+// NewPlanUsage creates an empty per-plan usage accumulator.
+func NewPlanUsage() *PlanUsage {
+	return &PlanUsage{}
+}
+
+// SetBudget limits the LLM spend of the plan.
+func (p *PlanUsage) SetBudget(budget Budget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.budget = budget
+}
+
+// Budget returns the budget of the plan.
+func (p *PlanUsage) Budget() Budget {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.budget
+}
+
+// Reserve holds tokens and cost against the budget for a call about to be
+// sent, failing with ErrBudgetExhausted if the recorded usage plus the
+// reservations of the calls in flight would exceed it. The returned release
+// function frees the reservation; call it once the call has been recorded
+// with Add or has failed.
+func (p *PlanUsage) Reserve(tokens int, cost float64) (release func(), err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.budget.MaxTokens > 0 {
+		committed := p.totals.TotalTokens + p.reservedTokens
+		if committed+tokens > p.budget.MaxTokens {
+			return nil, fmt.Errorf("%w: next call needs up to %d tokens, %d of %d remaining",
+				ErrBudgetExhausted, tokens, max(p.budget.MaxTokens-committed, 0), p.budget.MaxTokens)
+		}
+	}
+
+	if p.budget.MaxCost > 0 {
+		committed := p.totals.TotalCost + p.reservedCost
+		if committed+cost > p.budget.MaxCost {
+			return nil, fmt.Errorf("%w: next call may cost up to $%.4f, $%.4f of $%.4f remaining",
+				ErrBudgetExhausted, cost, max(p.budget.MaxCost-committed, 0), p.budget.MaxCost)
+		}
+	}
+
+	p.reservedTokens += tokens
+	p.reservedCost += cost
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			p.reservedTokens -= tokens
+			p.reservedCost -= cost
+		})
+	}, nil
+}
+
+// Add records a call made during the given phase and iteration.
+func (p *PlanUsage) Add(phase string, iteration int, resp *CompletionResponse, success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.totals.AddCall(resp, success)
+
+	entry := p.phase(phase, iteration)
+	entry.Calls++
+	if !success {
+		entry.FailedCalls++
+	}
+	if resp != nil {
+		entry.InputTokens += resp.InputTokens
+		entry.OutputTokens += resp.OutputTokens
+		entry.TotalTokens += resp.TokensUsed
+		entry.Cost += resp.Cost
+	}
+}
+
+// Totals returns the aggregate usage of the plan so far.
+func (p *PlanUsage) Totals() UsageStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.totals
+}
+
+// Phases returns the per-phase breakdown in the order phases were first used.
+func (p *PlanUsage) Phases() []PhaseUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	phases := make([]PhaseUsage, 0, len(p.phases))
+	for _, entry := range p.phases {
+		phases = append(phases, *entry)
+	}
+	return phases
+}
+
+// phase returns the entry for phase and iteration, creating it if needed.
+// The caller must hold p.mu.
+func (p *PlanUsage) phase(phase string, iteration int) *PhaseUsage {
+	for _, entry := range p.phases {
+		if entry.Phase == phase && entry.Iteration == iteration {
+			return entry
+		}
+	}
+
+	entry := &PhaseUsage{Phase: phase, Iteration: iteration}
+	p.phases = append(p.phases, entry)
+	return entry
+}
+
+// planUsageKey is the context key for the per-plan usage accumulator.
+type planUsageKey struct{}
+
+// WithPlanUsage returns a context carrying the per-plan usage accumulator.
+// Client.Complete records every call made with this context into it.
+func WithPlanUsage(ctx context.Context, usage *PlanUsage) context.Context {
+	return context.WithValue(ctx, planUsageKey{}, usage)
+}
+
+// PlanUsageFromContext returns the per-plan usage accumulator, or nil if none is set.
+func PlanUsageFromContext(ctx context.Context) *PlanUsage {
+	usage, _ := ctx.Value(planUsageKey{}).(*PlanUsage)
+	return usage
+}
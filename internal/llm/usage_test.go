@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestPlanUsageReserve(t *testing.T) {
+	usage := NewPlanUsage()
+	usage.SetBudget(Budget{MaxTokens: 1000})
+
+	release, err := usage.Reserve(600, 0)
+	if err != nil {
+		t.Fatalf("Reserve(600) error = %v", err)
+	}
+
+	// The reservation counts against the budget until released
+	if _, err := usage.Reserve(500, 0); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("Reserve(500) error = %v, want ErrBudgetExhausted", err)
+	}
+
+	// Settle: the call used 300 tokens
+	usage.Add("generation", 1, &CompletionResponse{TokensUsed: 300}, true)
+	release()
+	release() // releasing twice is harmless
+
+	if _, err := usage.Reserve(700, 0); err != nil {
+		t.Fatalf("Reserve(700) after settling error = %v", err)
+	}
+	if _, err := usage.Reserve(1, 0); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("Reserve(1) error = %v, want ErrBudgetExhausted", err)
+	}
+}
+
+func TestPlanUsageReserveCost(t *testing.T) {
+	usage := NewPlanUsage()
+	usage.SetBudget(Budget{MaxCost: 0.10})
+
+	release, err := usage.Reserve(0, 0.06)
+	if err != nil {
+		t.Fatalf("Reserve($0.06) error = %v", err)
+	}
+	if _, err := usage.Reserve(0, 0.06); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("second Reserve($0.06) error = %v, want ErrBudgetExhausted", err)
+	}
+
+	release()
+	if _, err := usage.Reserve(0, 0.06); err != nil {
+		t.Fatalf("Reserve($0.06) after release error = %v", err)
+	}
+}
+
+// gatedClient holds completions of the fake provider until gate is closed.
+type gatedClient struct {
+	*FakeClient
+	gate chan struct{}
+}
+
+func (c *gatedClient) Complete(ctx context.Context, req ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	<-c.gate
+	return c.FakeClient.Complete(ctx, req)
+}
+
+// TestConcurrentCallsRespectBudget sends concurrent calls against one plan
+// budget, as self-consistency sampling does. Only the calls whose estimate
+// fits alongside the ones in flight may be sent.
+func TestConcurrentCallsRespectBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	if err := os.WriteFile(path, []byte("default:\n  content: '{\"nodes\": {}}'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake, err := NewFakeClient(FakeConfig{ScriptPath: path}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewFakeClient() error = %v", err)
+	}
+	provider := &gatedClient{FakeClient: fake, gate: make(chan struct{})}
+	client := NewClient(provider, &config.LLMConfig{
+		Provider:    "fake",
+		Model:       "fake-model",
+		RetryConfig: config.RetryConfig{MaxAttempts: 1},
+	}, zap.NewNop())
+
+	newRequest := func() *CompletionRequest {
+		return &CompletionRequest{UserPrompt: "Plan it.", MaxTokens: 500}
+	}
+	estimate := estimatePromptTokens(newRequest()) + 500
+
+	usage := NewPlanUsage()
+	usage.SetBudget(Budget{MaxTokens: 2*estimate + estimate/2})
+	ctx := WithPlanUsage(context.Background(), usage)
+
+	const calls = 8
+	errs := make(chan error, calls)
+	for range calls {
+		go func() {
+			_, err := client.Complete(ctx, newRequest())
+			errs <- err
+		}()
+	}
+
+	// Every call beyond the two that fit is refused while those are in flight
+	refused := 0
+	timeout := time.After(5 * time.Second)
+	for refused < calls-2 {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrBudgetExhausted) {
+				t.Fatalf("Complete() error = %v before any call completed, want ErrBudgetExhausted", err)
+			}
+			refused++
+		case <-timeout:
+			close(provider.gate)
+			t.Fatalf("%d calls refused, want %d", refused, calls-2)
+		}
+	}
+
+	close(provider.gate)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("Complete() error = %v, want the call to succeed", err)
+		}
+	}
+
+	if totals := usage.Totals(); totals.TotalCalls != 2 {
+		t.Errorf("recorded %d calls, want 2", totals.TotalCalls)
+	}
+}
@@ -34,13 +34,15 @@ func (a *Analyzer) Analyze(ctx context.Context, task string) (*models.TaskAnalys
 	systemPrompt := a.buildSystemPrompt()
 	userPrompt := a.buildUserPrompt(task)
 
-	// Call LLM
-	resp, err := a.llmClient.Complete(ctx, &llm.CompletionRequest{
+	// Call LLM with the analysis profile
+	req := &llm.CompletionRequest{
 		SystemPrompt: systemPrompt,
 		UserPrompt:   userPrompt,
-		MaxTokens:    1024,
-		Temperature:  0.0,
-	})
+		Phase:        PhaseAnalysis,
+	}
+	a.llmClient.Profile(PhaseAnalysis).Apply(req)
+
+	resp, err := a.llmClient.Complete(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("LLM analysis failed: %w", err)
 	}
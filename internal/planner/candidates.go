@@ -0,0 +1,213 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Points of the ranking of JSON candidates. A candidate passing schema
+// validation outranks any that does not; ties go to the later candidate.
+const (
+	schemaValidPoints = 40
+	graphFieldPoints  = 10 // per graph field present (nodes, edges, entry node)
+	lastPoints        = 2  // the candidate appears last in the response
+	largestPoints     = 1  // the candidate is the largest in the response
+)
+
+// codeBlock matches fenced code blocks.
+var codeBlock = regexp.MustCompile("```(?:json)?\\s*\\n([^`]+)```")
+
+// jsonCandidate is a JSON object found in an LLM response.
+type jsonCandidate struct {
+	kind    string   // "code block" or "object"
+	offset  int      // byte offset in the response
+	source  string   // text of the candidate in the response
+	text    string   // JSON of the candidate, repaired if source is malformed
+	repairs []string // repairs applied to source, if any
+
+	// Set by ranking
+	graphJSON string
+	reasoning string
+	score     int
+	problems  []string
+}
+
+// describe identifies the candidate in diagnostics.
+func (c *jsonCandidate) describe() string {
+	kind := c.kind
+	if len(c.repairs) > 0 {
+		kind = "repaired " + kind
+	}
+	return fmt.Sprintf("%s at offset %d (%d bytes)", kind, c.offset, len(c.source))
+}
+
+// jsonCandidates returns the JSON values of content in order of preference
+// for extraction without ranking: code blocks first, then top-level objects
+// outside them, each in order of appearance. Code blocks and objects that
+// fail to parse are repaired leniently and kept if the repair succeeds.
+func jsonCandidates(content string) []*jsonCandidate {
+	var candidates []*jsonCandidate
+	seen := make(map[string]bool)
+
+	add := func(kind string, offset int, source string) {
+		if seen[source] {
+			return
+		}
+		seen[source] = true
+
+		text, repairs := source, []string(nil)
+		if !isValidJSON(source) {
+			if text, repairs = repairJSON(source); text == "" {
+				return
+			}
+		}
+		if seen[text] && text != source {
+			return
+		}
+		seen[text] = true
+
+		candidates = append(candidates, &jsonCandidate{
+			kind:    kind,
+			offset:  offset,
+			source:  source,
+			text:    text,
+			repairs: repairs,
+		})
+	}
+
+	for _, m := range codeBlock.FindAllStringSubmatchIndex(content, -1) {
+		block := content[m[2]:m[3]]
+		text := strings.TrimSpace(block)
+		add("code block", m[2]+strings.Index(block, text), text)
+	}
+
+	// Balanced top-level objects, skipping braces inside strings
+	var depth int
+	start := -1
+	var inString bool
+	var escape bool
+
+	for i, ch := range content {
+		if escape {
+			escape = false
+			continue
+		}
+
+		if ch == '\\' {
+			escape = true
+			continue
+		}
+
+		if ch == '"' {
+			inString = !inString
+			continue
+		}
+
+		if inString {
+			continue
+		}
+
+		switch ch {
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 && start >= 0 {
+				add("object", start, content[start:i+1])
+			}
+		}
+	}
+
+	return candidates
+}
+
+// rankCandidates scores each candidate as a graph and returns the best one.
+// Envelopes are ranked on the graph they hold.
+func (e *Extractor) rankCandidates(candidates []*jsonCandidate) *jsonCandidate {
+	last, largest := candidates[0], candidates[0]
+	for _, c := range candidates {
+		if c.offset > last.offset {
+			last = c
+		}
+		if len(c.text) > len(largest.text) {
+			largest = c
+		}
+	}
+
+	var best *jsonCandidate
+	for _, c := range candidates {
+		graphJSON, reasoning, ok := unwrapEnvelope(c.text)
+		if !ok {
+			graphJSON = c.text
+		}
+		c.graphJSON, c.reasoning = graphJSON, reasoning
+
+		var fields map[string]json.RawMessage
+		_ = json.Unmarshal([]byte(graphJSON), &fields)
+
+		var missing []string
+		for _, names := range [][]string{{"nodes"}, {"edges"}, {"entry_node", "entry_point"}} {
+			found := false
+			for _, name := range names {
+				if _, ok := fields[name]; ok {
+					found = true
+				}
+			}
+			if found {
+				c.score += graphFieldPoints
+			} else {
+				missing = append(missing, names[0])
+			}
+		}
+		if len(missing) > 0 {
+			c.problems = append(c.problems, "missing "+strings.Join(missing, ", "))
+		}
+
+		if e.schemaValidator != nil {
+			if err := e.schemaValidator.ValidateGraph([]byte(graphJSON)); err == nil {
+				c.score += schemaValidPoints
+			} else {
+				c.problems = append(c.problems, "fails schema validation")
+			}
+		}
+
+		if c == last {
+			c.score += lastPoints
+		}
+		if c == largest {
+			c.score += largestPoints
+		}
+
+		if best == nil || c.score > best.score || (c.score == best.score && c.offset > best.offset) {
+			best = c
+		}
+	}
+
+	return best
+}
+
+// skippedDiagnostics explains why the candidates other than best were not
+// extracted.
+func skippedDiagnostics(candidates []*jsonCandidate, best *jsonCandidate) []string {
+	var diagnostics []string
+	for _, c := range candidates {
+		if c == best {
+			continue
+		}
+
+		reason := fmt.Sprintf("outranked by %s (score %d vs %d)", best.describe(), c.score, best.score)
+		if len(c.problems) > 0 {
+			reason = strings.Join(c.problems, "; ") + "; " + reason
+		}
+		diagnostics = append(diagnostics, fmt.Sprintf("skipped %s: %s", c.describe(), reason))
+	}
+	return diagnostics
+}
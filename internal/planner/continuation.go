@@ -0,0 +1,114 @@
+package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"go.uber.org/zap"
+)
+
+// Bounds of the overlap removed when a continuation repeats the end of the
+// partial output. Shorter overlaps are likely to be legitimate text.
+const (
+	minOverlapChars = 16
+	maxOverlapChars = 200
+)
+
+// continueTruncated issues continuation requests while resp was cut off at
+// the output token limit, up to MaxContinuations. It returns the response
+// with the joined content and the number of continuation requests made.
+// A failed continuation leaves the output truncated.
+func (g *Generator) continueTruncated(
+	ctx context.Context,
+	req *llm.CompletionRequest,
+	resp *llm.CompletionResponse,
+) (*llm.CompletionResponse, int) {
+	merged := *resp
+	continuations := 0
+
+	for merged.Truncated() && continuations < g.config.MaxContinuations {
+		continuations++
+
+		// Replay the request with the partial output as the assistant turn
+		messages := make([]ports.Message, 0, len(req.Messages)+2)
+		messages = append(messages, req.Messages...)
+		messages = append(messages,
+			ports.Message{Role: "user", Content: req.UserPrompt},
+			ports.Message{Role: "assistant", Content: merged.Content},
+		)
+
+		contReq := &llm.CompletionRequest{
+			SystemPrompt: req.SystemPrompt,
+			Messages:     messages,
+			UserPrompt:   g.prompter.GetContinuationPrompt(),
+			Model:        req.Model,
+			MaxTokens:    req.MaxTokens,
+			Temperature:  req.Temperature,
+			Timeout:      req.Timeout,
+			Phase:        PhaseContinuation,
+			Iteration:    req.Iteration,
+		}
+
+		g.logger.Debug("continuing truncated response",
+			zap.Int("iteration", req.Iteration),
+			zap.Int("continuation", continuations),
+			zap.Int("partial_length", len(merged.Content)),
+		)
+
+		next, err := g.llmClient.Complete(ctx, contReq)
+		if err != nil {
+			g.logger.Warn("continuation request failed, keeping truncated response",
+				zap.Int("iteration", req.Iteration),
+				zap.Int("continuation", continuations),
+				zap.Error(err),
+			)
+			break
+		}
+
+		merged.Content = joinContinuation(merged.Content, next.Content)
+		merged.FinishReason = next.FinishReason
+		merged.Provider = next.Provider
+		merged.Model = next.Model
+	}
+
+	return &merged, continuations
+}
+
+// joinContinuation appends a continuation to the partial output, dropping
+// a code block the continuation reopens and any text it repeats from the
+// end of the partial output.
+func joinContinuation(partial, next string) string {
+	next = trimReopenedBlock(partial, next)
+
+	maxOverlap := min(len(partial), len(next), maxOverlapChars)
+	for n := maxOverlap; n >= minOverlapChars; n-- {
+		if strings.HasSuffix(partial, next[:n]) {
+			return partial + next[n:]
+		}
+	}
+	return partial + next
+}
+
+// trimReopenedBlock drops the opening fence of a code block that the
+// continuation starts again, along with a one-line introduction ending in a
+// colon ("Continuing the JSON:"). A bare fence that closes a block left open
+// by the partial output is kept.
+func trimReopenedBlock(partial, next string) string {
+	rest := strings.TrimLeft(next, " \t\r\n")
+	if line, after, ok := strings.Cut(rest, "\n"); ok && strings.HasSuffix(strings.TrimSpace(line), ":") {
+		if after = strings.TrimLeft(after, " \t\r\n"); strings.HasPrefix(after, "```") {
+			rest = after
+		}
+	}
+	if !strings.HasPrefix(rest, "```") {
+		return next
+	}
+
+	fence, after, _ := strings.Cut(rest, "\n")
+	if strings.TrimSpace(fence) == "```" && strings.Count(partial, "```")%2 == 1 {
+		return next
+	}
+	return after
+}
@@ -0,0 +1,71 @@
+package planner
+
+import "testing"
+
+func TestJoinContinuation(t *testing.T) {
+	partial := "```json\n{\"id\": \"welcome_email\", \"nodes\": {\"send\": {\"id\": \"send\", \"type\": \"exec"
+
+	tests := []struct {
+		name    string
+		partial string
+		next    string
+		want    string
+	}{
+		{
+			name:    "no overlap",
+			partial: partial,
+			next:    "utor\"}}}\n```",
+			want:    partial + "utor\"}}}\n```",
+		},
+		{
+			name:    "repeated tail",
+			partial: partial,
+			next:    "{\"id\": \"send\", \"type\": \"executor\"}}}",
+			want:    partial + "utor\"}}}",
+		},
+		{
+			name:    "overlap shorter than the minimum is kept",
+			partial: partial,
+			next:    "\"exec\": 1}",
+			want:    partial + "\"exec\": 1}",
+		},
+		{
+			name:    "continuation longer than the partial output",
+			partial: "{\"id\": \"welcome_email\",",
+			next:    "{\"id\": \"welcome_email\", \"nodes\": {}}",
+			want:    "{\"id\": \"welcome_email\", \"nodes\": {}}",
+		},
+		{
+			name:    "reopened code block",
+			partial: partial,
+			next:    "```json\n{\"id\": \"send\", \"type\": \"executor\"}}}\n```",
+			want:    partial + "utor\"}}}\n```",
+		},
+		{
+			name:    "introduction before a reopened code block",
+			partial: partial,
+			next:    "Continuing from where I left off:\n\n```json\nutor\"}}}\n```",
+			want:    partial + "utor\"}}}\n```",
+		},
+		{
+			name:    "fence closing the open code block",
+			partial: "```json\n{\"id\": \"welcome_email\"}\n",
+			next:    "```\nThat is the graph.",
+			want:    "```json\n{\"id\": \"welcome_email\"}\n```\nThat is the graph.",
+		},
+		{
+			name:    "introduction without a code block",
+			partial: "{\"nodes\":",
+			next:    "Here is the rest:\n{\"a\": {}}}",
+			want:    "{\"nodes\":Here is the rest:\n{\"a\": {}}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinContinuation(tt.partial, tt.next); got != tt.want {
+				t.Errorf("joinContinuation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
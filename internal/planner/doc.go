@@ -28,12 +28,20 @@
 //    - Extract key entities and intent
 //
 // 2. Graph Generation:
-//    - Build planning prompt with schemas
-//    - Call LLM to generate graph
-//    - Extract JSON from response
-//    - Validate against schemas
-//    - If validation fails, iterate with error feedback
+//    - Route by task complexity (planning.routes) to a model and limits
+//    - Build planning prompt with schemas, trimming the context and
+//      analysis to fit the model's context window
+//    - Call LLM to generate graph, continuing responses cut off at the
+//      output token limit (planning.max_continuations)
+//    - Extract JSON from response, repairing malformed JSON leniently
+//    - Validate against schemas, then lint the graph (planning.enable_lint)
+//    - If validation fails, iterate with error feedback, continuing the
+//      conversation with the failed attempts (planning.repair_history)
+//    - Escalate the model, restart from the planning prompt or raise the
+//      temperature after repeated failures (planning.strategies)
 //    - Return valid graph or error after max iterations
+//    - With sampling (planning.samples), generate several candidates
+//      concurrently and return the best scoring valid one
 //
 // 3. Response Assembly:
 //    - Package graph with metadata
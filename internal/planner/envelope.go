@@ -0,0 +1,82 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+)
+
+// graphEnvelopeSchema is the response schema requested from providers with
+// native structured output: the graph together with the reasoning behind it.
+// It only describes the graph's shape; the full dago-libs schema is still
+// enforced by validation.
+var graphEnvelopeSchema = ports.JSONSchema{
+	"title":    "graph_plan",
+	"type":     "object",
+	"required": []string{"reasoning", "graph"},
+	"properties": map[string]interface{}{
+		"reasoning": map[string]interface{}{
+			"type":        "string",
+			"description": "Explanation of the graph design",
+		},
+		"graph": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"id", "nodes", "entry_node"},
+			"properties": map[string]interface{}{
+				"id":          map[string]interface{}{"type": "string"},
+				"name":        map[string]interface{}{"type": "string"},
+				"description": map[string]interface{}{"type": "string"},
+				"nodes": map[string]interface{}{
+					"type":        "object",
+					"description": "Map of node ID to node definition",
+					"additionalProperties": map[string]interface{}{
+						"type":     "object",
+						"required": []string{"id", "type"},
+						"properties": map[string]interface{}{
+							"id":   map[string]interface{}{"type": "string"},
+							"type": map[string]interface{}{"type": "string", "enum": []string{"executor", "router"}},
+						},
+					},
+				},
+				"edges": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type":     "object",
+						"required": []string{"from", "to"},
+						"properties": map[string]interface{}{
+							"from":      map[string]interface{}{"type": "string"},
+							"to":        map[string]interface{}{"type": "string"},
+							"condition": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+				"entry_node": map[string]interface{}{"type": "string"},
+			},
+		},
+	},
+}
+
+// unwrapEnvelope returns the graph and reasoning of a graph envelope. ok is
+// false if data is not a JSON object holding a graph object.
+func unwrapEnvelope(data string) (graphJSON string, reasoning string, ok bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return "", "", false
+	}
+
+	graph := bytes.TrimSpace(fields["graph"])
+	if len(graph) == 0 || graph[0] != '{' {
+		return "", "", false
+	}
+
+	if raw, found := fields["reasoning"]; found {
+		if err := json.Unmarshal(raw, &reasoning); err != nil {
+			// Not a string; keep the raw JSON rather than losing it
+			reasoning = string(raw)
+		}
+	}
+
+	return string(graph), strings.TrimSpace(reasoning), true
+}
@@ -0,0 +1,54 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/aescanero/dago-node-planner/pkg/models"
+)
+
+// BudgetExhaustedError is returned when a plan runs out of its token or cost
+// budget before a valid graph is produced. It carries the best graph
+// generated so far, which may be missing or fail validation.
+type BudgetExhaustedError struct {
+	// GraphJSON is the last graph extracted from the LLM, if any
+	GraphJSON string
+
+	// Reasoning is the LLM's reasoning for GraphJSON
+	Reasoning string
+
+	// Iterations is the number of iterations performed
+	Iterations int
+
+	// ValidationLogs contains validation messages from each iteration
+	ValidationLogs []string
+
+	// Continuations is the number of continuation requests for truncated responses
+	Continuations int
+
+	// Reports describes each iteration performed
+	Reports []models.IterationReport
+
+	// Provider and Model identify the LLM that produced GraphJSON; empty
+	// if no call completed
+	Provider string
+	Model    string
+
+	// Plan is the partial plan response assembled by the Service
+	Plan *models.PlanResponse
+
+	// Err is the underlying error; it wraps llm.ErrBudgetExhausted
+	Err error
+}
+
+// Error implements the error interface.
+func (e *BudgetExhaustedError) Error() string {
+	if e.GraphJSON == "" {
+		return fmt.Sprintf("planning budget exhausted after %d iterations without a graph: %v", e.Iterations, e.Err)
+	}
+	return fmt.Sprintf("planning budget exhausted after %d iterations, returning best graph so far: %v", e.Iterations, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BudgetExhaustedError) Unwrap() error {
+	return e.Err
+}
@@ -6,50 +6,122 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/aescanero/dago-libs/pkg/schema"
+	"github.com/aescanero/dago-node-planner/pkg/models"
 	"go.uber.org/zap"
 )
 
+// emptyCodeBlock matches the code block fences left once the JSON inside
+// them has been cut out.
+var emptyCodeBlock = regexp.MustCompile("```[a-zA-Z]*\\s*```")
+
 // Extractor extracts graph JSON from LLM responses.
 type Extractor struct {
-	logger *zap.Logger
+	schemaValidator *schema.Validator
+	logger          *zap.Logger
 }
 
-// NewExtractor creates a new extractor.
-func NewExtractor(logger *zap.Logger) *Extractor {
+// NewExtractor creates a new extractor. The schema validator, which may be
+// nil, ranks the JSON candidates of a response.
+func NewExtractor(schemaValidator *schema.Validator, logger *zap.Logger) *Extractor {
 	return &Extractor{
-		logger: logger,
+		schemaValidator: schemaValidator,
+		logger:          logger,
 	}
 }
 
-// Extract extracts graph JSON and reasoning from an LLM response.
-func (e *Extractor) Extract(content string) (graphJSON string, reasoning string, err error) {
+// Extraction is a graph extracted from an LLM response.
+type Extraction struct {
+	GraphJSON string   // Graph JSON, unwrapped from the envelope
+	Reasoning string   // LLM's reasoning
+	Repairs   []string // Repairs applied to malformed JSON, if any
+
+	// Diagnostics explains why the other JSON candidates of the response
+	// were skipped
+	Diagnostics []string
+}
+
+// Extract extracts graph JSON and reasoning from an LLM response. A
+// {"reasoning", "graph"} envelope is unwrapped; otherwise the JSON is taken
+// as a bare graph and the reasoning looked for in the surrounding text.
+// When the response holds several JSON values, the one most likely to be
+// the final graph is taken; malformed ones are repaired leniently and
+// ranked with the others.
+func (e *Extractor) Extract(content string) (*Extraction, error) {
 	e.logger.Debug("extracting graph from LLM response")
 
-	// Try to extract reasoning
-	reasoning = e.extractReasoning(content)
+	result := &Extraction{}
+
+	// Collect and rank JSON candidates
+	var source, graphJSON, reasoning string
+	if candidates := jsonCandidates(content); len(candidates) > 0 {
+		best := e.rankCandidates(candidates)
+		source, graphJSON, reasoning = best.source, best.graphJSON, best.reasoning
+		result.Repairs = best.repairs
+		result.Diagnostics = skippedDiagnostics(candidates, best)
+		if len(result.Diagnostics) > 0 {
+			e.logger.Debug("selected JSON candidate",
+				zap.String("candidate", best.describe()),
+				zap.Int("score", best.score),
+				zap.Strings("skipped", result.Diagnostics),
+			)
+		}
+	} else {
+		// Truncated output has no balanced object to repair
+		source = repairCandidate(content)
+		jsonStr, repairs := repairJSON(source)
+		if jsonStr == "" {
+			return nil, fmt.Errorf("no JSON found in response")
+		}
+		e.logger.Debug("repaired malformed JSON",
+			zap.Strings("repairs", repairs),
+		)
+
+		var ok bool
+		result.Repairs = repairs
+		if graphJSON, reasoning, ok = unwrapEnvelope(jsonStr); !ok {
+			graphJSON = jsonStr
+		}
+	}
 
-	// Extract JSON
-	graphJSON = extractJSON(content)
-	if graphJSON == "" {
-		return "", "", fmt.Errorf("no JSON found in response")
+	// Fall back to reasoning written outside the JSON
+	if reasoning == "" {
+		text := strings.Replace(content, source, "", 1)
+		reasoning = e.extractReasoning(emptyCodeBlock.ReplaceAllString(text, ""))
 	}
 
-	// Validate it's valid JSON
+	result.GraphJSON = graphJSON
+	result.Reasoning = reasoning
+	return result, nil
+}
+
+// ExtractEnvelope extracts graph JSON and reasoning from a structured
+// response, which holds the graph envelope as a JSON object.
+func (e *Extractor) ExtractEnvelope(content string) (*Extraction, error) {
+	e.logger.Debug("extracting graph from structured LLM response")
+
 	var temp interface{}
-	if err := json.Unmarshal([]byte(graphJSON), &temp); err != nil {
-		return "", "", fmt.Errorf("invalid JSON: %w", err)
+	if err := json.Unmarshal([]byte(content), &temp); err != nil {
+		return nil, fmt.Errorf("invalid structured response: %w", err)
+	}
+
+	graphJSON, reasoning, ok := unwrapEnvelope(content)
+	if !ok {
+		return nil, fmt.Errorf("no graph found in structured response")
 	}
 
-	return graphJSON, reasoning, nil
+	return &Extraction{GraphJSON: graphJSON, Reasoning: reasoning}, nil
 }
 
-// extractReasoning extracts the reasoning section from the response.
+// extractReasoning extracts the reasoning section from the text around the
+// graph JSON.
 func (e *Extractor) extractReasoning(content string) string {
 	// Look for reasoning in various formats
 	patterns := []string{
+		`(?s)#+\s*Reasoning:?\s+(.+?)(?:\n#|$)`,
+		`(?s)\*\*Reasoning:?\*\*:?\s*(.+?)(?:\n\s*\n|$)`,
 		`(?s)reasoning["\s:]+([^{]+)`,
 		`(?s)Reasoning[:\s]+([^{]+)`,
-		`(?s)## Reasoning\s+([^#]+)`,
 	}
 
 	for _, pattern := range patterns {
@@ -65,88 +137,25 @@ func (e *Extractor) extractReasoning(content string) string {
 	return ""
 }
 
-// ParseGraph parses a graph JSON string into a map.
-func (e *Extractor) ParseGraph(graphJSON string) (map[string]any, error) {
-	var graph map[string]any
-	if err := json.Unmarshal([]byte(graphJSON), &graph); err != nil {
-		return nil, fmt.Errorf("failed to parse graph JSON: %w", err)
-	}
-	return graph, nil
+// ParseGraph parses a graph JSON string.
+func (e *Extractor) ParseGraph(graphJSON string) (*models.Graph, error) {
+	return models.ParseGraph([]byte(graphJSON))
 }
 
-// extractJSON extracts JSON from text content.
-// It looks for JSON objects and arrays, preferring complete, well-formed structures.
+// extractJSON extracts JSON from text content: the first valid code block,
+// or else the first valid top-level object, or else the first that could
+// be repaired.
 func extractJSON(content string) string {
-	// Try to find JSON in code blocks first
-	codeBlockPatterns := []string{
-		"```json\\s*\\n([^`]+)```",
-		"```\\s*\\n([^`]+)```",
-	}
-
-	for _, pattern := range codeBlockPatterns {
-		re := regexp.MustCompile(pattern)
-		if matches := re.FindStringSubmatch(content); len(matches) > 1 {
-			jsonStr := strings.TrimSpace(matches[1])
-			if isValidJSON(jsonStr) {
-				return jsonStr
-			}
+	candidates := jsonCandidates(content)
+	for _, c := range candidates {
+		if len(c.repairs) == 0 {
+			return c.text
 		}
 	}
-
-	// Try to find raw JSON objects
-	// Look for { ... } that spans multiple lines
-	re := regexp.MustCompile(`(?s)\{[^}]*"nodes"[^}]*\}`)
-	if matches := re.FindStringSubmatch(content); len(matches) > 0 {
-		jsonStr := matches[0]
-		if isValidJSON(jsonStr) {
-			return jsonStr
-		}
+	if len(candidates) == 0 {
+		return ""
 	}
-
-	// Last resort: try to find any valid JSON object
-	var depth int
-	var start int
-	var inString bool
-	var escape bool
-
-	for i, ch := range content {
-		if escape {
-			escape = false
-			continue
-		}
-
-		if ch == '\\' {
-			escape = true
-			continue
-		}
-
-		if ch == '"' {
-			inString = !inString
-			continue
-		}
-
-		if inString {
-			continue
-		}
-
-		switch ch {
-		case '{':
-			if depth == 0 {
-				start = i
-			}
-			depth++
-		case '}':
-			depth--
-			if depth == 0 && start > 0 {
-				jsonStr := content[start : i+1]
-				if isValidJSON(jsonStr) {
-					return jsonStr
-				}
-			}
-		}
-	}
-
-	return ""
+	return candidates[0].text
 }
 
 // isValidJSON checks if a string is valid JSON.
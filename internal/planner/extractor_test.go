@@ -0,0 +1,169 @@
+package planner
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/schema"
+	"go.uber.org/zap"
+)
+
+const testGraph = `{
+  "id": "welcome_email",
+  "nodes": {
+    "send": {"id": "send", "type": "executor", "executor_type": "tool", "config": {"tool_name": "send_email"}}
+  },
+  "edges": [],
+  "entry_node": "send"
+}`
+
+func newTestExtractor(t *testing.T) *Extractor {
+	t.Helper()
+
+	validator, err := schema.NewValidator()
+	if err != nil {
+		t.Fatalf("schema.NewValidator() error = %v", err)
+	}
+	return NewExtractor(validator, zap.NewNop())
+}
+
+// graphID returns the id of the extracted graph.
+func graphID(t *testing.T, graphJSON string) string {
+	t.Helper()
+
+	var graph struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(graphJSON), &graph); err != nil {
+		t.Fatalf("extracted graph is not valid JSON: %v\n%s", err, graphJSON)
+	}
+	return graph.ID
+}
+
+func TestExtract(t *testing.T) {
+	envelope := `{"reasoning": "One tool call sends the email", "graph": ` + testGraph + `}`
+	snippet := `{"id": "example", "nodes": {"a": {"id": "a", "type": "executor", "executor_type": "llm", "config": {}}}, "edges": [], "entry_node": "a"}`
+
+	tests := []struct {
+		name          string
+		content       string
+		wantID        string
+		wantReasoning string
+		wantRepairs   []string
+	}{
+		{
+			name:          "envelope",
+			content:       envelope,
+			wantID:        "welcome_email",
+			wantReasoning: "One tool call sends the email",
+		},
+		{
+			name:          "bare graph with markdown reasoning",
+			content:       "## Reasoning\nOne tool call sends the email\n\n```json\n" + testGraph + "\n```",
+			wantID:        "welcome_email",
+			wantReasoning: "One tool call sends the email",
+		},
+		{
+			name:          "example snippet before the final graph",
+			content:       "A node looks like:\n```json\n" + snippet + "\n```\nHere is the plan:\n```json\n" + envelope + "\n```",
+			wantID:        "welcome_email",
+			wantReasoning: "One tool call sends the email",
+		},
+		{
+			name: "valid snippet before a malformed envelope",
+			content: "For example:\n```json\n" + snippet + "\n```\nThe plan:\n```json\n" +
+				strings.Replace(envelope, `"entry_node": "send"`, `"entry_node": "send",`, 1) + "\n```",
+			wantID:        "welcome_email",
+			wantReasoning: "One tool call sends the email",
+			wantRepairs:   []string{"removed trailing commas"},
+		},
+		{
+			name:          "malformed object in prose",
+			content:       "Here you go: {reasoning: 'One tool call sends the email', graph: " + testGraph + "} Enjoy!",
+			wantID:        "welcome_email",
+			wantReasoning: "One tool call sends the email",
+			wantRepairs:   []string{"quoted bare keys", "converted single-quoted strings"},
+		},
+		{
+			name:          "truncated code block",
+			content:       "```json\n" + envelope[:len(envelope)-2],
+			wantID:        "welcome_email",
+			wantReasoning: "One tool call sends the email",
+			wantRepairs:   []string{"closed 2 unclosed brackets"},
+		},
+	}
+
+	extractor := newTestExtractor(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractor.Extract(tt.content)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+
+			if id := graphID(t, got.GraphJSON); id != tt.wantID {
+				t.Errorf("graph id = %q, want %q", id, tt.wantID)
+			}
+			if got.Reasoning != tt.wantReasoning {
+				t.Errorf("Reasoning = %q, want %q", got.Reasoning, tt.wantReasoning)
+			}
+			if !slices.Equal(got.Repairs, tt.wantRepairs) {
+				t.Errorf("Repairs = %q, want %q", got.Repairs, tt.wantRepairs)
+			}
+		})
+	}
+}
+
+func TestExtractNoJSON(t *testing.T) {
+	if _, err := newTestExtractor(t).Extract("I could not come up with a plan."); err == nil {
+		t.Fatal("Extract() error = nil, want an error for a response without JSON")
+	}
+}
+
+func TestExtractDiagnostics(t *testing.T) {
+	content := "```json\n{\"note\": \"not a graph\"}\n```\n```json\n" + testGraph + "\n```"
+
+	got, err := newTestExtractor(t).Extract(content)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	if len(got.Diagnostics) != 1 {
+		t.Fatalf("Diagnostics = %q, want one skipped candidate", got.Diagnostics)
+	}
+	if d := got.Diagnostics[0]; !strings.HasPrefix(d, "skipped code block at offset 8") || !strings.Contains(d, "missing nodes, edges, entry_node") {
+		t.Errorf("Diagnostics[0] = %q, want the skipped code block and its problems", d)
+	}
+}
+
+func TestJSONCandidatesRepair(t *testing.T) {
+	content := "```json\n{\"a\": 1,}\n```\nand {b: 2} but not {this}"
+
+	candidates := jsonCandidates(content)
+	if len(candidates) != 2 {
+		t.Fatalf("jsonCandidates() = %d candidates, want 2", len(candidates))
+	}
+
+	want := []struct {
+		kind, source, text string
+	}{
+		{kind: "code block", source: `{"a": 1,}`, text: `{"a": 1}`},
+		{kind: "object", source: `{b: 2}`, text: `{"b": 2}`},
+	}
+	for i, w := range want {
+		c := candidates[i]
+		if c.kind != w.kind || c.source != w.source || c.text != w.text || len(c.repairs) == 0 {
+			t.Errorf("candidate %d = %s %q -> %q (repairs %q), want repaired %s %q -> %q",
+				i, c.kind, c.source, c.text, c.repairs, w.kind, w.source, w.text)
+		}
+		if content[c.offset:c.offset+len(c.source)] != c.source {
+			t.Errorf("candidate %d offset %d does not point at its source", i, c.offset)
+		}
+	}
+
+	if got := extractJSON(`{'a': 1} {"b": 2}`); got != `{"b": 2}` {
+		t.Errorf("extractJSON() = %q, want the first valid object", got)
+	}
+}
@@ -2,29 +2,47 @@ package planner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/aescanero/dago-libs/pkg/ports"
 	"github.com/aescanero/dago-libs/pkg/schema"
+	"github.com/aescanero/dago-node-planner/internal/config"
 	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/internal/planner/lint"
 	"github.com/aescanero/dago-node-planner/pkg/models"
 	"go.uber.org/zap"
 )
 
 // GenerateRequest represents a request to generate a graph.
 type GenerateRequest struct {
-	Task        string
-	Context     map[string]any
-	Analysis    *models.TaskAnalysis
-	Constraints *models.Constraints
+	Task              string
+	Context           map[string]any
+	Analysis          *models.TaskAnalysis
+	Constraints       *models.Constraints
+	Samples           int     // Candidate graphs to generate concurrently (1 or less: a single one)
+	SampleTemperature float64 // Temperature of candidate generation when sampling
+	Model             string  // Overrides the model of generation and repair calls
+	MaxTokens         int     // Overrides the output tokens of generation and repair calls
+	MaxIterations     int     // Overrides the refinement iteration limit
 }
 
 // GenerateResponse represents the result of graph generation.
 type GenerateResponse struct {
-	Graph          any      // The generated graph (parsed JSON)
-	GraphJSON      string   // Raw JSON string
-	Reasoning      string   // LLM's reasoning
-	Iterations     int      // Number of iterations performed
-	ValidationLogs []string // Validation logs from each iteration
+	Graph          *models.Graph             // The generated graph
+	GraphJSON      string                    // Raw JSON string
+	Reasoning      string                    // LLM's reasoning
+	Iterations     int                       // Number of iterations performed
+	ValidationLogs []string                  // Validation logs from each iteration
+	Continuations  int                       // Continuation requests made for truncated responses
+	Provider       string                    // LLM provider that produced the final graph
+	Model          string                    // LLM model that produced the final graph
+	Samples        int                       // Candidate graphs generated (0 without sampling)
+	Score          float64                   // Score of the selected candidate when sampling
+	Alternatives   []models.CandidateSummary // Summaries of the candidates not selected
+	Reports        []models.IterationReport  // Per-iteration reports of the refinement loop
+	Warnings       []string                  // Lint warnings of the final graph
 }
 
 // Generator orchestrates graph generation with iterative refinement.
@@ -34,9 +52,18 @@ type Generator struct {
 	extractor       *Extractor
 	schemaValidator *schema.Validator
 	iterator        *Iterator
+	config          *config.PlanningConfig
 	logger          *zap.Logger
 }
 
+// repairTurn is a failed attempt of a multi-turn repair conversation.
+type repairTurn struct {
+	attempt  int      // iteration that produced the response
+	response string   // assistant output of the attempt
+	errors   []string // errors of the attempt and of later iterations without a response
+	feedback string   // validation feedback sent in reply
+}
+
 // NewGenerator creates a new graph generator.
 func NewGenerator(
 	llmClient *llm.Client,
@@ -44,6 +71,7 @@ func NewGenerator(
 	extractor *Extractor,
 	schemaValidator *schema.Validator,
 	iterator *Iterator,
+	cfg *config.PlanningConfig,
 	logger *zap.Logger,
 ) *Generator {
 	return &Generator{
@@ -52,96 +80,265 @@ func NewGenerator(
 		extractor:       extractor,
 		schemaValidator: schemaValidator,
 		iterator:        iterator,
+		config:          cfg,
 		logger:          logger,
 	}
 }
 
-// Generate generates a graph from a task with iterative refinement.
+// Generate generates a graph from a task with iterative refinement. With
+// more than one sample, candidates are generated concurrently and the best
+// valid one is returned.
 func (g *Generator) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
-	g.logger.Debug("starting graph generation")
+	g.logger.Debug("starting graph generation",
+		zap.Int("samples", max(req.Samples, 1)),
+	)
+
+	if req.Samples > 1 {
+		return g.generateSamples(ctx, req)
+	}
+	return g.generate(ctx, req, false)
+}
 
+// generate generates a single graph with iterative refinement, using the
+// generation and repair profiles. Sampled candidates are generated at the
+// sample temperature instead of the profiles' temperatures.
+func (g *Generator) generate(ctx context.Context, req *GenerateRequest, sampled bool) (*GenerateResponse, error) {
 	// Get schemas for prompt
 	schemas, err := g.getSchemas()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get schemas: %w", err)
 	}
 
+	generation := g.llmClient.Profile(PhaseGeneration)
+	repair := g.llmClient.Profile(PhaseRepair)
+	if sampled {
+		generation.Temperature = req.SampleTemperature
+		repair.Temperature = req.SampleTemperature
+	}
+	if req.Model != "" {
+		generation.Model = req.Model
+		repair.Model = req.Model
+	}
+	if req.MaxTokens > 0 {
+		generation.MaxTokens = req.MaxTokens
+		repair.MaxTokens = req.MaxTokens
+	}
+
+	iterator := g.iterator
+	if req.MaxIterations > 0 {
+		iterator = iterator.WithMaxIterations(req.MaxIterations)
+	}
+
+	// Prompts are sized to the smallest context window of the fallback chain
+	budgetReq := &llm.CompletionRequest{SystemPrompt: g.prompter.GetSystemPrompt()}
+	generation.Apply(budgetReq)
+	promptBudget, err := g.promptBudget(budgetReq)
+	if err != nil {
+		return nil, err
+	}
+	repair.Apply(budgetReq)
+	repairBudget, err := g.promptBudget(budgetReq)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initial generation
-	prompt, err := g.prompter.BuildPlanningPrompt(req.Task, req.Analysis, schemas, req.Constraints)
+	prompt, err := g.prompter.BuildPlanningPrompt(req.Task, req.Context, req.Analysis, schemas, req.Constraints, promptBudget)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build planning prompt: %w", err)
 	}
 
 	var graphJSON string
+	var graph *models.Graph
 	var reasoning string
 	var validationLogs []string
+	var provider, model string
+	var turns []repairTurn
+	var reports []models.IterationReport
+	var warnings []string
 	iteration := 0
+	continuations := 0
+
+	// logFailure records an iteration error in the validation logs and in the
+	// feedback on the last response, so that the next repair sees it even
+	// when the failed iteration produced no response of its own
+	logFailure := func(msg string) {
+		validationLogs = append(validationLogs, msg)
+		if last := len(turns) - 1; last >= 0 {
+			turns[last].errors = append(turns[last].errors, msg)
+		}
+	}
 
 	// Iterative refinement loop
-	err = g.iterator.Iterate(ctx, func(ctx context.Context, attempt int) error {
+	err = iterator.Iterate(ctx, func(ctx context.Context, iter *Iteration) (err error) {
+		attempt := iter.Attempt
 		iteration = attempt
 
-		var llmResp *llm.CompletionResponse
-		var llmErr error
+		phase, profile := PhaseGeneration, generation
+		if attempt > 1 && !iter.Restart {
+			phase, profile = PhaseRepair, repair
+		}
+		if iter.Model != "" {
+			profile.Model = iter.Model
+		}
+		if iter.TemperatureBoost > 0 {
+			profile.Temperature = max(profile.Temperature, min(profile.Temperature+iter.TemperatureBoost, iter.MaxTemperature))
+		}
+
+		report := models.IterationReport{
+			Iteration:   attempt,
+			Phase:       phase,
+			Model:       profile.Model,
+			Temperature: profile.Temperature,
+			Restarted:   iter.Restart,
+			Actions:     iter.Actions,
+		}
+		defer func() {
+			if iteration < attempt {
+				return // never sent
+			}
+			if err != nil {
+				report.Error = err.Error()
+			}
+			reports = append(reports, report)
+		}()
+
+		completionReq := &llm.CompletionRequest{
+			SystemPrompt:   g.prompter.GetSystemPrompt(),
+			UserPrompt:     prompt,
+			ResponseSchema: graphEnvelopeSchema,
+			Phase:          phase,
+			Iteration:      attempt,
+		}
+		profile.Apply(completionReq)
 
-		if attempt == 1 {
+		switch {
+		case attempt == 1:
 			// First attempt: use planning prompt
-			llmResp, llmErr = g.llmClient.Complete(ctx, &llm.CompletionRequest{
-				SystemPrompt: g.prompter.GetSystemPrompt(),
-				UserPrompt:   prompt,
-				MaxTokens:    4096,
-				Temperature:  0.0,
-			})
-		} else {
+
+		case iter.Restart:
+			// Start over from the planning prompt, dropping the failed attempts
+			turns = nil
+
+		case g.config.RepairHistory > 0:
+			// Subsequent attempts: reply to the last failed attempt in the conversation
+			if last := len(turns) - 1; last >= 0 {
+				turns[last].feedback = g.prompter.BuildRepairFeedback(turns[last].errors, turns[last].attempt)
+			}
+			g.repairConversation(completionReq, prompt, turns)
+
+		default:
 			// Subsequent attempts: use error-fixing prompt
 			validationErrs := validationLogs[len(validationLogs)-1]
-			fixPrompt, err := g.prompter.BuildErrorFixingPrompt(req.Task, graphJSON, []string{validationErrs}, attempt)
+			budget := repairBudget
+			if iter.Model != "" {
+				if budget, err = g.promptBudget(completionReq); err != nil {
+					return err
+				}
+			}
+			fixPrompt, err := g.prompter.BuildErrorFixingPrompt(req.Task, graphJSON, []string{validationErrs}, attempt, budget)
 			if err != nil {
 				return fmt.Errorf("failed to build error-fixing prompt: %w", err)
 			}
-
-			llmResp, llmErr = g.llmClient.Complete(ctx, &llm.CompletionRequest{
-				SystemPrompt: g.prompter.GetSystemPrompt(),
-				UserPrompt:   fixPrompt,
-				MaxTokens:    4096,
-				Temperature:  0.0,
-			})
+			completionReq.UserPrompt = fixPrompt
 		}
 
+		llmResp, llmErr := g.llmClient.Complete(ctx, completionReq)
 		if llmErr != nil {
+			if errors.Is(llmErr, llm.ErrBudgetExhausted) {
+				// The call was never sent, so this iteration did not happen
+				iteration = attempt - 1
+			} else {
+				// Keep the failure for the error-fixing prompt of the next attempt
+				logFailure(fmt.Sprintf("LLM error: %s", llmErr))
+			}
 			return fmt.Errorf("LLM request failed: %w", llmErr)
 		}
 
-		// Extract graph JSON
-		extractedJSON, extractedReasoning, err := g.extractor.Extract(llmResp.Content)
+		// Complete output cut off at the token limit before extracting
+		attemptContinuations := 0
+		if llmResp.Truncated() {
+			llmResp, attemptContinuations = g.continueTruncated(ctx, completionReq, llmResp)
+			continuations += attemptContinuations
+		}
+
+		provider = llmResp.Provider
+		model = llmResp.Model
+		turns = append(turns, repairTurn{attempt: attempt, response: llmResp.Content})
+
+		if llmResp.Truncated() {
+			errMsg := fmt.Sprintf("Extraction error: response truncated at the output token limit after %d continuations", attemptContinuations)
+			logFailure(errMsg)
+			return errors.New(errMsg)
+		}
+
+		// Extract graph JSON; structured responses need no text heuristics
+		var extraction *Extraction
+		if llmResp.Structured {
+			extraction, err = g.extractor.ExtractEnvelope(llmResp.Content)
+		} else {
+			extraction, err = g.extractor.Extract(llmResp.Content)
+		}
 		if err != nil {
-			validationLogs = append(validationLogs, fmt.Sprintf("Extraction error: %s", err))
+			logFailure(fmt.Sprintf("Extraction error: %s", err))
 			return err
 		}
+		if len(extraction.Repairs) > 0 {
+			validationLogs = append(validationLogs, fmt.Sprintf("Extraction repaired malformed JSON: %s", strings.Join(extraction.Repairs, ", ")))
+		}
 
-		graphJSON = extractedJSON
-		reasoning = extractedReasoning
+		graphJSON = extraction.GraphJSON
+		reasoning = extraction.Reasoning
 
 		// Validate graph
 		if err := g.schemaValidator.ValidateGraph([]byte(graphJSON)); err != nil {
-			errMsg := fmt.Sprintf("Validation failed: %s", err.Error())
-			validationLogs = append(validationLogs, errMsg)
+			logFailure(fmt.Sprintf("Validation failed: %s", err.Error()))
 			return err
 		}
 
+		// A graph the model cannot hold is repaired like a schema error
+		graph, err = g.extractor.ParseGraph(graphJSON)
+		if err != nil {
+			logFailure(fmt.Sprintf("Validation failed: %s", err))
+			return err
+		}
+
+		// Lint errors are repaired like schema errors
+		warnings = nil
+		if g.config.EnableLint {
+			findings, lintErr := lint.LintJSON([]byte(graphJSON))
+			if lintErr != nil {
+				logFailure(fmt.Sprintf("Lint failed: %s", lintErr))
+				return fmt.Errorf("graph lint failed: %w", lintErr)
+			}
+			if errs := lint.Errors(findings); len(errs) > 0 {
+				summary := strings.Join(lint.Strings(errs), "; ")
+				logFailure(fmt.Sprintf("Lint failed: %s", summary))
+				return fmt.Errorf("graph lint failed: %s", summary)
+			}
+			warnings = lint.Strings(lint.Warnings(findings))
+		}
+
 		// Success!
 		validationLogs = append(validationLogs, "Validation successful")
 		return nil
 	})
 
-	if err != nil {
-		return nil, fmt.Errorf("graph generation failed after %d iterations: %w", iteration, err)
+	if errors.Is(err, llm.ErrBudgetExhausted) {
+		return nil, &BudgetExhaustedError{
+			GraphJSON:      graphJSON,
+			Reasoning:      reasoning,
+			Iterations:     iteration,
+			ValidationLogs: validationLogs,
+			Continuations:  continuations,
+			Reports:        reports,
+			Provider:       provider,
+			Model:          model,
+			Err:            err,
+		}
 	}
-
-	// Parse final graph
-	graph, err := g.extractor.ParseGraph(graphJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse final graph: %w", err)
+		return nil, fmt.Errorf("graph generation failed after %d iterations: %w", iteration, err)
 	}
 
 	resp := &GenerateResponse{
@@ -150,6 +347,11 @@ func (g *Generator) Generate(ctx context.Context, req *GenerateRequest) (*Genera
 		Reasoning:      reasoning,
 		Iterations:     iteration,
 		ValidationLogs: validationLogs,
+		Continuations:  continuations,
+		Reports:        reports,
+		Warnings:       warnings,
+		Provider:       provider,
+		Model:          model,
 	}
 
 	g.logger.Debug("graph generation completed",
@@ -159,6 +361,61 @@ func (g *Generator) Generate(ctx context.Context, req *GenerateRequest) (*Genera
 	return resp, nil
 }
 
+// promptBudget returns how many tokens the user prompt of req may take, or
+// zero if the context windows of the models are unknown.
+func (g *Generator) promptBudget(req *llm.CompletionRequest) (int, error) {
+	budget, ok := g.llmClient.PromptBudget(req)
+	if !ok {
+		return 0, nil
+	}
+	if budget <= 0 {
+		return 0, fmt.Errorf("%w: no room left for the prompt", llm.ErrContextWindowExceeded)
+	}
+	return budget, nil
+}
+
+// repairConversation sets the conversation of a repair attempt on req: the
+// planning request, the most recent failed attempts (up to RepairHistory)
+// each followed by its feedback, and the feedback on the last one as the
+// new user prompt. Older attempts are left out while the conversation does
+// not fit the context window. Without failed attempts the planning request
+// is resent.
+func (g *Generator) repairConversation(req *llm.CompletionRequest, prompt string, turns []repairTurn) {
+	if len(turns) == 0 {
+		req.Messages, req.UserPrompt = nil, prompt
+		return
+	}
+
+	if len(turns) > g.config.RepairHistory {
+		turns = turns[len(turns)-g.config.RepairHistory:]
+	}
+
+	for {
+		req.Messages, req.UserPrompt = conversation(prompt, turns)
+		if len(turns) == 1 {
+			return
+		}
+		if budget, ok := g.llmClient.PromptBudget(req); !ok || llm.EstimateTokens(req.UserPrompt) <= budget {
+			return
+		}
+		turns = turns[1:]
+	}
+}
+
+// conversation lays out the planning request and failed attempts as
+// messages, returning the feedback on the last attempt as the user prompt.
+func conversation(prompt string, turns []repairTurn) ([]ports.Message, string) {
+	messages := []ports.Message{{Role: "user", Content: prompt}}
+	for i, turn := range turns {
+		messages = append(messages, ports.Message{Role: "assistant", Content: turn.response})
+		if i < len(turns)-1 {
+			messages = append(messages, ports.Message{Role: "user", Content: turn.feedback})
+		}
+	}
+
+	return messages, turns[len(turns)-1].feedback
+}
+
 // getSchemas returns schema information for prompts.
 // Note: Full schemas are embedded in dago-libs and used for validation.
 // For prompts, we provide a simplified description.
@@ -0,0 +1,132 @@
+package planner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago-libs/pkg/schema"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/pkg/models"
+	"go.uber.org/zap"
+)
+
+// repairScript answers the planning request with a graph failing lint, then
+// fails the first repair with a rate limit error and answers the second
+// with prose before returning a valid graph.
+const repairScript = `
+model: fake-planner
+responses:
+  - times: 1
+    content: '{"reasoning": "Send it", "graph": {"id": "welcome_email", "nodes": {"send": {"id": "send", "type": "executor", "executor_type": "tool", "config": {"tool_name": "send_email"}}}, "edges": [{"from": "send", "to": "log_result"}], "entry_node": "send"}}'
+  - times: 1
+    fault: rate_limit
+  - times: 1
+    fault: prose
+default:
+  content: '{"reasoning": "Send it", "graph": {"id": "welcome_email", "nodes": {"send": {"id": "send", "type": "executor", "executor_type": "tool", "config": {"tool_name": "send_email"}}}, "edges": [], "entry_node": "send"}}'
+`
+
+// recordingClient records the requests sent to the wrapped client.
+type recordingClient struct {
+	ports.LLMClient
+	requests []ports.CompletionRequest
+}
+
+func (c *recordingClient) Complete(ctx context.Context, req ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	c.requests = append(c.requests, req)
+	return c.LLMClient.Complete(ctx, req)
+}
+
+func TestRepairConversationErrors(t *testing.T) {
+	logger := zap.NewNop()
+
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	if err := os.WriteFile(path, []byte(repairScript), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	fake, err := llm.NewFakeClient(llm.FakeConfig{ScriptPath: path}, logger)
+	if err != nil {
+		t.Fatalf("NewFakeClient() error = %v", err)
+	}
+	recorder := &recordingClient{LLMClient: fake}
+
+	client := llm.NewClient(recorder, &config.LLMConfig{
+		Provider:    "fake",
+		Model:       "fake-planner",
+		MaxTokens:   4096,
+		RetryConfig: config.RetryConfig{MaxAttempts: 1},
+	}, logger)
+
+	validator, err := schema.NewValidator()
+	if err != nil {
+		t.Fatalf("schema.NewValidator() error = %v", err)
+	}
+	service := NewService(client, validator, &config.PlanningConfig{
+		MaxIterations:    4,
+		MaxNodes:         50,
+		EnableValidation: true,
+		EnableLint:       true,
+		RepairHistory:    2,
+		Samples:          1,
+	}, logger)
+
+	if _, err := service.Plan(context.Background(), &models.PlanRequest{Task: "Send a welcome email to the new user"}); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	// Each request: the roles after the system prompt, and what the user
+	// turns must mention
+	want := []struct {
+		roles    []string
+		feedback [][]string // per user turn after the planning request
+	}{
+		{roles: []string{"user"}},
+		{
+			roles:    []string{"user", "assistant", "user"},
+			feedback: [][]string{{"attempt 1", "Lint failed", "log_result"}},
+		},
+		{
+			roles:    []string{"user", "assistant", "user"},
+			feedback: [][]string{{"attempt 1", "Lint failed", "LLM error", "rate_limit"}},
+		},
+		{
+			roles: []string{"user", "assistant", "user", "assistant", "user"},
+			feedback: [][]string{
+				{"attempt 1", "Lint failed", "LLM error", "rate_limit"},
+				{"attempt 3", "Extraction error"},
+			},
+		},
+	}
+
+	if len(recorder.requests) != len(want) {
+		t.Fatalf("sent %d requests, want %d", len(recorder.requests), len(want))
+	}
+	for i, w := range want {
+		messages := recorder.requests[i].Messages[1:]
+
+		var roles []string
+		var feedback []string
+		for j, m := range messages {
+			roles = append(roles, m.Role)
+			if m.Role == "user" && j > 0 {
+				feedback = append(feedback, m.Content)
+			}
+		}
+		if strings.Join(roles, ",") != strings.Join(w.roles, ",") {
+			t.Errorf("request %d roles = %v, want %v", i+1, roles, w.roles)
+			continue
+		}
+		for j, wantParts := range w.feedback {
+			for _, part := range wantParts {
+				if !strings.Contains(feedback[j], part) {
+					t.Errorf("request %d user turn %d = %q, want it to mention %q", i+1, j+2, feedback[j], part)
+				}
+			}
+		}
+	}
+}
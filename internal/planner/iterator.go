@@ -2,32 +2,73 @@ package planner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/aescanero/dago-node-planner/internal/llm"
 	"go.uber.org/zap"
 )
 
 // Iterator manages iterative refinement of graph generation.
 type Iterator struct {
 	maxIterations int
+	strategies    []Strategy
 	logger        *zap.Logger
 }
 
-// NewIterator creates a new iterator.
-func NewIterator(maxIterations int, logger *zap.Logger) *Iterator {
+// Iteration describes one iteration of the refinement loop, as adjusted by
+// the iteration strategies.
+type Iteration struct {
+	// Attempt is the 1-based iteration number
+	Attempt int
+
+	// Model overrides the model of the iteration ("" keeps the configured one)
+	Model string
+
+	// TemperatureBoost is added to the temperature of the iteration, up to MaxTemperature
+	TemperatureBoost float64
+
+	// MaxTemperature caps the boosted temperature
+	MaxTemperature float64
+
+	// Restart starts over from the planning prompt instead of repairing
+	Restart bool
+
+	// Actions describes the strategy decisions applied to the iteration
+	Actions []string
+
+	// Err is the error the iteration failed with, set once it has run
+	Err error
+}
+
+// NewIterator creates a new iterator. Strategies adjust each iteration
+// after a failed one.
+func NewIterator(maxIterations int, strategies []Strategy, logger *zap.Logger) *Iterator {
 	return &Iterator{
 		maxIterations: maxIterations,
+		strategies:    strategies,
 		logger:        logger,
 	}
 }
 
+// WithMaxIterations returns a copy of the iterator with a different
+// iteration limit.
+func (it *Iterator) WithMaxIterations(maxIterations int) *Iterator {
+	return &Iterator{
+		maxIterations: maxIterations,
+		strategies:    it.strategies,
+		logger:        it.logger,
+	}
+}
+
 // IterateFunc is a function that performs a single iteration.
 // It should return nil on success, or an error to trigger another iteration.
-type IterateFunc func(ctx context.Context, attempt int) error
+type IterateFunc func(ctx context.Context, iteration *Iteration) error
 
 // Iterate performs iterative refinement up to maxIterations.
 func (it *Iterator) Iterate(ctx context.Context, fn IterateFunc) error {
 	var lastErr error
+	var history []*Iteration
 
 	for attempt := 1; attempt <= it.maxIterations; attempt++ {
 		it.logger.Debug("starting iteration",
@@ -35,6 +76,19 @@ func (it *Iterator) Iterate(ctx context.Context, fn IterateFunc) error {
 			zap.Int("max_iterations", it.maxIterations),
 		)
 
+		iteration := &Iteration{Attempt: attempt}
+		if attempt > 1 {
+			for _, s := range it.strategies {
+				s.Next(history, iteration)
+			}
+		}
+		if len(iteration.Actions) > 0 {
+			it.logger.Info("iteration strategy applied",
+				zap.Int("attempt", attempt),
+				zap.Strings("actions", iteration.Actions),
+			)
+		}
+
 		// Check context
 		select {
 		case <-ctx.Done():
@@ -43,7 +97,7 @@ func (it *Iterator) Iterate(ctx context.Context, fn IterateFunc) error {
 		}
 
 		// Execute iteration
-		err := fn(ctx, attempt)
+		err := fn(ctx, iteration)
 		if err == nil {
 			it.logger.Debug("iteration succeeded",
 				zap.Int("attempt", attempt),
@@ -52,6 +106,27 @@ func (it *Iterator) Iterate(ctx context.Context, fn IterateFunc) error {
 		}
 
 		lastErr = err
+		iteration.Err = err
+		history = append(history, iteration)
+
+		// Another iteration cannot be paid for
+		if errors.Is(err, llm.ErrBudgetExhausted) {
+			it.logger.Debug("iteration budget exhausted, stopping",
+				zap.Int("attempt", attempt),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		// Retrying cannot make the request fit
+		if errors.Is(err, llm.ErrContextWindowExceeded) {
+			it.logger.Debug("request exceeds the context window, stopping",
+				zap.Int("attempt", attempt),
+				zap.Error(err),
+			)
+			return err
+		}
+
 		it.logger.Debug("iteration failed, will retry",
 			zap.Int("attempt", attempt),
 			zap.Error(err),
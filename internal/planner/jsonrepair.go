@@ -0,0 +1,268 @@
+package planner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxRepairCuts bounds how many incomplete trailing values are dropped when
+// repairing truncated JSON.
+const maxRepairCuts = 8
+
+// openCodeBlock matches a JSON code block, which may be cut off before its
+// closing fence.
+var openCodeBlock = regexp.MustCompile("(?s)```(?:json)?\\s*\\n(.*?)(?:```|$)")
+
+// repairCandidate returns the text most likely to hold the malformed JSON of
+// a response: the first code block starting with an object, or else
+// everything from the first '{'.
+func repairCandidate(content string) string {
+	for _, m := range openCodeBlock.FindAllStringSubmatch(content, -1) {
+		if block := strings.TrimSpace(m[1]); strings.HasPrefix(block, "{") {
+			return block
+		}
+	}
+
+	if start := strings.Index(content, "{"); start >= 0 {
+		return content[start:]
+	}
+	return ""
+}
+
+// repairJSON leniently parses the first JSON value of s and returns it as
+// strict JSON, with the repairs that were needed. It accepts comments,
+// trailing commas, single-quoted and smart-quoted strings, bare keys,
+// Python literals and raw newlines in strings, and closes the brackets of
+// truncated output, dropping incomplete trailing values if needed. It
+// returns "" if s cannot be repaired.
+func repairJSON(s string) (string, []string) {
+	in := []rune(s)
+	r := &jsonRepairer{}
+	out, cuts := r.repair(in)
+	if isValidJSON(out) {
+		return out, r.repairs
+	}
+
+	// Truncated output may end inside a value; drop values from the end
+	for i := len(cuts) - 1; i >= 0 && i >= len(cuts)-maxRepairCuts; i-- {
+		r := &jsonRepairer{}
+		out, _ := r.repair(in[:cuts[i]])
+		if isValidJSON(out) {
+			r.note("dropped incomplete trailing value")
+			return out, r.repairs
+		}
+	}
+
+	return "", nil
+}
+
+// jsonRepairer rewrites lenient JSON into strict JSON.
+type jsonRepairer struct {
+	out     strings.Builder
+	stack   []rune // closing brackets of the open objects and arrays
+	repairs []string
+}
+
+// note records a repair once.
+func (r *jsonRepairer) note(repair string) {
+	for _, existing := range r.repairs {
+		if existing == repair {
+			return
+		}
+	}
+	r.repairs = append(r.repairs, repair)
+}
+
+// repair rewrites the first JSON value of in. It returns the output and the
+// positions of the commas outside strings, where truncated input can be cut
+// back to a complete value.
+func (r *jsonRepairer) repair(in []rune) (string, []int) {
+	var cuts []int
+
+	for i := 0; i < len(in); i++ {
+		ch := in[i]
+		next := rune(0)
+		if i+1 < len(in) {
+			next = in[i+1]
+		}
+
+		switch {
+		case ch == '/' && next == '/', ch == '#':
+			for i+1 < len(in) && in[i+1] != '\n' {
+				i++
+			}
+			r.note("removed comments")
+
+		case ch == '/' && next == '*':
+			end := strings.Index(string(in[i+2:]), "*/")
+			if end < 0 {
+				return r.finish(), cuts
+			}
+			i += 3 + len([]rune(string(in[i+2:])[:end]))
+			r.note("removed comments")
+
+		case ch == '"' || ch == '\'' || ch == '“' || ch == '”' || ch == '‘' || ch == '’':
+			n, closed := r.readString(in[i:])
+			i += n - 1
+			if !closed {
+				return r.finish(), cuts
+			}
+			if len(r.stack) == 0 {
+				return r.out.String(), cuts
+			}
+
+		case ch == '{' || ch == '[':
+			if ch == '{' {
+				r.stack = append(r.stack, '}')
+			} else {
+				r.stack = append(r.stack, ']')
+			}
+			r.out.WriteRune(ch)
+
+		case ch == '}' || ch == ']':
+			if len(r.stack) == 0 {
+				return r.out.String(), cuts
+			}
+			r.trimTrailingComma()
+			if r.stack[len(r.stack)-1] != ch {
+				r.note("balanced brackets")
+			}
+			r.out.WriteRune(r.stack[len(r.stack)-1])
+			r.stack = r.stack[:len(r.stack)-1]
+			if len(r.stack) == 0 {
+				return r.out.String(), cuts
+			}
+
+		case ch == ',':
+			cuts = append(cuts, i)
+			r.out.WriteRune(ch)
+
+		case unicode.IsLetter(ch) || ch == '_' || ch == '$':
+			n := 1
+			for i+n < len(in) && (unicode.IsLetter(in[i+n]) || unicode.IsDigit(in[i+n]) || in[i+n] == '_' || in[i+n] == '$') {
+				n++
+			}
+			word := string(in[i : i+n])
+			i += n - 1
+			r.writeWord(word, in[i+1:])
+			if len(r.stack) == 0 {
+				return r.out.String(), cuts
+			}
+
+		default:
+			r.out.WriteRune(ch)
+		}
+	}
+
+	return r.finish(), cuts
+}
+
+// readString rewrites the string starting at in[0] as a double-quoted JSON
+// string. It returns the runes consumed and whether the string was closed.
+func (r *jsonRepairer) readString(in []rune) (int, bool) {
+	open := in[0]
+	switch open {
+	case '\'':
+		r.note("converted single-quoted strings")
+	case '“', '”', '‘', '’':
+		r.note("replaced smart quotes")
+	}
+	closes := func(ch rune) bool {
+		switch open {
+		case '"', '\'':
+			return ch == open
+		case '“', '”':
+			return ch == '”' || ch == '“'
+		default:
+			return ch == '’' || ch == '‘'
+		}
+	}
+
+	r.out.WriteRune('"')
+	for i := 1; i < len(in); i++ {
+		ch := in[i]
+		switch {
+		case ch == '\\' && i+1 < len(in):
+			i++
+			if in[i] == '\'' {
+				r.out.WriteRune('\'')
+			} else {
+				r.out.WriteRune('\\')
+				r.out.WriteRune(in[i])
+			}
+		case closes(ch):
+			r.out.WriteRune('"')
+			return i + 1, true
+		case ch == '"':
+			r.out.WriteString(`\"`)
+		case ch == '\n':
+			r.out.WriteString(`\n`)
+			r.note("escaped control characters in strings")
+		case ch == '\r' || ch == '\t':
+			fmt.Fprintf(&r.out, `\u%04x`, ch)
+			r.note("escaped control characters in strings")
+		default:
+			r.out.WriteRune(ch)
+		}
+	}
+
+	r.out.WriteRune('"')
+	r.note("closed unterminated string")
+	return len(in), false
+}
+
+// writeWord writes a bare word: a literal, a number or an object key.
+// rest is the input following the word.
+func (r *jsonRepairer) writeWord(word string, rest []rune) {
+	if strings.HasPrefix(strings.TrimLeftFunc(string(rest), unicode.IsSpace), ":") {
+		fmt.Fprintf(&r.out, "%q", word)
+		r.note("quoted bare keys")
+		return
+	}
+
+	switch word {
+	case "True":
+		word = "true"
+	case "False":
+		word = "false"
+	case "None", "undefined":
+		word = "null"
+	default:
+		r.out.WriteString(word)
+		return
+	}
+	r.out.WriteString(word)
+	r.note("replaced non-JSON literals")
+}
+
+// trimTrailingComma removes a comma left before a closing bracket.
+func (r *jsonRepairer) trimTrailingComma() {
+	out := r.out.String()
+	trimmed := strings.TrimRightFunc(out, unicode.IsSpace)
+	if strings.HasSuffix(trimmed, ",") {
+		r.out.Reset()
+		r.out.WriteString(strings.TrimSuffix(trimmed, ","))
+		r.note("removed trailing commas")
+	}
+}
+
+// finish closes the brackets left open by truncated input.
+func (r *jsonRepairer) finish() string {
+	if len(r.stack) == 0 {
+		return r.out.String()
+	}
+
+	r.trimTrailingComma()
+	if strings.HasSuffix(strings.TrimRightFunc(r.out.String(), unicode.IsSpace), ":") {
+		r.out.WriteString("null")
+	}
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		r.out.WriteRune(r.stack[i])
+	}
+	r.note(fmt.Sprintf("closed %d unclosed brackets", len(r.stack)))
+	r.stack = nil
+
+	return r.out.String()
+}
@@ -0,0 +1,326 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// truncatedAfterBadValue returns an object truncated after n members that
+// follow an incomplete value, so that n+1 values must be dropped to repair it.
+func truncatedAfterBadValue(n int) string {
+	var b strings.Builder
+	b.WriteString(`{"x": 0, "a": tru`)
+	for i := range n {
+		fmt.Fprintf(&b, `, "b%d": 1`, i)
+	}
+	return b.String()
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		want        string
+		wantRepairs []string
+	}{
+		{
+			name: "valid JSON",
+			in:   `{"a": "say \"hi\"", "b": [1, 2]}`,
+			want: `{"a": "say \"hi\"", "b": [1, 2]}`,
+		},
+		{
+			name: "text after the value",
+			in:   `{"a": "x"} and that is the graph`,
+			want: `{"a": "x"}`,
+		},
+		{
+			name:        "trailing commas",
+			in:          `{"a": 1, "b": [1, 2,],}`,
+			want:        `{"a": 1, "b": [1, 2]}`,
+			wantRepairs: []string{"removed trailing commas"},
+		},
+		{
+			name:        "line comments",
+			in:          "{\"a\": 1, // the first\n \"b\": 2}",
+			want:        "{\"a\": 1, \n \"b\": 2}",
+			wantRepairs: []string{"removed comments"},
+		},
+		{
+			name:        "hash comments",
+			in:          "{\"a\": 1 # the first\n}",
+			want:        "{\"a\": 1 \n}",
+			wantRepairs: []string{"removed comments"},
+		},
+		{
+			name:        "block comments",
+			in:          `{"a": /* inline */ 1}`,
+			want:        `{"a":  1}`,
+			wantRepairs: []string{"removed comments"},
+		},
+		{
+			name:        "comment markers inside strings",
+			in:          `{"url": "http://example.com/#top", "glob": "/*"}`,
+			want:        `{"url": "http://example.com/#top", "glob": "/*"}`,
+			wantRepairs: nil,
+		},
+		{
+			name:        "single quotes",
+			in:          `{'a': 'it\'s "quoted"'}`,
+			want:        `{"a": "it's \"quoted\""}`,
+			wantRepairs: []string{"converted single-quoted strings"},
+		},
+		{
+			name:        "smart double quotes",
+			in:          `{“a”: “b”}`,
+			want:        `{"a": "b"}`,
+			wantRepairs: []string{"replaced smart quotes"},
+		},
+		{
+			name:        "smart single quotes",
+			in:          `{‘a’: ‘b’}`,
+			want:        `{"a": "b"}`,
+			wantRepairs: []string{"replaced smart quotes"},
+		},
+		{
+			name:        "bare keys",
+			in:          `{a: 1, b_c: "x", $d: {e : 2}}`,
+			want:        `{"a": 1, "b_c": "x", "$d": {"e" : 2}}`,
+			wantRepairs: []string{"quoted bare keys"},
+		},
+		{
+			name:        "Python and JavaScript literals",
+			in:          `{"a": True, "b": False, "c": None, "d": undefined, "e": true}`,
+			want:        `{"a": true, "b": false, "c": null, "d": null, "e": true}`,
+			wantRepairs: []string{"replaced non-JSON literals"},
+		},
+		{
+			name:        "raw newlines and tabs in strings",
+			in:          "{\"a\": \"line1\nline2\ttab\r\"}",
+			want:        `{"a": "line1\nline2\u0009tab\u000d"}`,
+			wantRepairs: []string{"escaped control characters in strings"},
+		},
+		{
+			name:        "several repairs",
+			in:          "{nodes: {'a': {enabled: True,},}, // done\n}",
+			want:        `{"nodes": {"a": {"enabled": true}}}`,
+			wantRepairs: []string{"quoted bare keys", "converted single-quoted strings", "replaced non-JSON literals", "removed trailing commas", "removed comments"},
+		},
+		{
+			name:        "mismatched bracket",
+			in:          `{"a": [1, 2}`,
+			want:        `{"a": [1, 2]}`,
+			wantRepairs: []string{"balanced brackets", "closed 1 unclosed brackets"},
+		},
+		{
+			name:        "truncated inside an array",
+			in:          `{"a": {"b": [1, 2`,
+			want:        `{"a": {"b": [1, 2]}}`,
+			wantRepairs: []string{"closed 3 unclosed brackets"},
+		},
+		{
+			name:        "truncated after a comma",
+			in:          `{"a": [1, 2,`,
+			want:        `{"a": [1, 2]}`,
+			wantRepairs: []string{"removed trailing commas", "closed 2 unclosed brackets"},
+		},
+		{
+			name:        "truncated inside a string",
+			in:          `{"a": "unterminated`,
+			want:        `{"a": "unterminated"}`,
+			wantRepairs: []string{"closed unterminated string", "closed 1 unclosed brackets"},
+		},
+		{
+			name:        "truncated after a key",
+			in:          `{"a":`,
+			want:        `{"a":null}`,
+			wantRepairs: []string{"closed 1 unclosed brackets"},
+		},
+		{
+			name:        "truncated inside a comment",
+			in:          `{"a": 1 /* unfinished`,
+			want:        `{"a": 1 }`,
+			wantRepairs: []string{"closed 1 unclosed brackets"},
+		},
+		{
+			name:        "truncated inside a literal",
+			in:          `{"a": 1, "b": tru`,
+			want:        `{"a": 1}`,
+			wantRepairs: []string{"closed 1 unclosed brackets", "dropped incomplete trailing value"},
+		},
+		{
+			name:        "incomplete value within the cut limit",
+			in:          truncatedAfterBadValue(maxRepairCuts - 1),
+			want:        `{"x": 0}`,
+			wantRepairs: []string{"closed 1 unclosed brackets", "dropped incomplete trailing value"},
+		},
+		{
+			name: "incomplete value beyond the cut limit",
+			in:   truncatedAfterBadValue(maxRepairCuts),
+			want: "",
+		},
+		{
+			name: "not JSON",
+			in:   "no JSON here",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repairs := repairJSON(tt.in)
+			if got != tt.want {
+				t.Errorf("repairJSON() = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && !isValidJSON(got) {
+				t.Errorf("repairJSON() = %q, which is not valid JSON", got)
+			}
+			if !slices.Equal(repairs, tt.wantRepairs) {
+				t.Errorf("repairs = %q, want %q", repairs, tt.wantRepairs)
+			}
+		})
+	}
+}
+
+func TestRepairCandidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "code block",
+			content: "Here it is:\n```json\n{\"a\": 1,}\n```\nDone.",
+			want:    `{"a": 1,}`,
+		},
+		{
+			name:    "unclosed code block",
+			content: "Here it is:\n```json\n{\"a\": [1, 2",
+			want:    `{"a": [1, 2`,
+		},
+		{
+			name:    "code block without an object",
+			content: "```bash\nls -l\n```\nThe graph is {\"a\": 1,}",
+			want:    `{"a": 1,}`,
+		},
+		{
+			name:    "bare object",
+			content: "The graph is {a: 1} as requested",
+			want:    "{a: 1} as requested",
+		},
+		{
+			name:    "no object",
+			content: "I cannot plan this task.",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repairCandidate(tt.content); got != tt.want {
+				t.Errorf("repairCandidate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExtractResponseCorpus extracts the graphs from the full LLM responses in
+// testdata/responses and compares them with the golden graphs next to them,
+// which -update rewrites.
+func TestExtractResponseCorpus(t *testing.T) {
+	tests := []struct {
+		name          string
+		wantReasoning string
+		wantRepairs   []string
+	}{
+		{
+			name:          "fenced-with-comments",
+			wantReasoning: "An LLM node scores the sentiment, a router escalates negative feedback and everything else gets a ticket.",
+			wantRepairs:   []string{"removed comments", "removed trailing commas"},
+		},
+		{
+			name:          "smart-quotes",
+			wantReasoning: "A single tool call emails the report, so no routing is needed.",
+			wantRepairs:   []string{"replaced smart quotes"},
+		},
+		{
+			name:          "truncated-max-tokens",
+			wantReasoning: "Fetch the order, then notify the customer and update the database in sequence.",
+			wantRepairs:   []string{"closed unterminated string", "closed 3 unclosed brackets"},
+		},
+		{
+			name:          "truncated-mid-literal",
+			wantReasoning: "Classify the ticket with an LLM, then file it.",
+			wantRepairs:   []string{"closed 3 unclosed brackets", "dropped incomplete trailing value"},
+		},
+		{
+			name:          "python-dict",
+			wantReasoning: "The task only needs one LLM call to summarize the document, so the graph is a single executor node.",
+			wantRepairs:   []string{"converted single-quoted strings", "replaced non-JSON literals"},
+		},
+		{
+			name:          "example-then-plan",
+			wantReasoning: "Translation and storage are two sequential steps.",
+			wantRepairs:   []string{"quoted bare keys"},
+		},
+	}
+
+	extractor := newTestExtractor(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := filepath.Join("testdata", "responses", tt.name)
+			content, err := os.ReadFile(base + ".txt")
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			got, err := extractor.Extract(string(content))
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+
+			if got.Reasoning != tt.wantReasoning {
+				t.Errorf("Reasoning = %q, want %q", got.Reasoning, tt.wantReasoning)
+			}
+			if !slices.Equal(got.Repairs, tt.wantRepairs) {
+				t.Errorf("Repairs = %q, want %q", got.Repairs, tt.wantRepairs)
+			}
+
+			var graph any
+			if err := json.Unmarshal([]byte(got.GraphJSON), &graph); err != nil {
+				t.Fatalf("extracted graph is not valid JSON: %v\n%s", err, got.GraphJSON)
+			}
+
+			golden := base + ".golden.json"
+			if *update {
+				var buf strings.Builder
+				encoder := json.NewEncoder(&buf)
+				encoder.SetEscapeHTML(false)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(graph); err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				if err := os.WriteFile(golden, []byte(buf.String()), 0o644); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			}
+
+			data, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			var want any
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatalf("golden graph %s is not valid JSON: %v", golden, err)
+			}
+			if !reflect.DeepEqual(graph, want) {
+				t.Errorf("extracted graph = %s, want %s", got.GraphJSON, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+// Package lint checks the semantics of graphs that pass schema validation:
+// references between nodes, reachability, routing and cycles.
+//
+// Every finding carries a stable rule ID and a severity. Errors make a graph
+// unusable and are fed back to the LLM for repair; warnings are reported
+// alongside valid graphs.
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aescanero/dago-node-planner/pkg/models"
+)
+
+// Severity is the severity of a finding.
+type Severity string
+
+// Severities.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Rule IDs. They are part of the API and must not change.
+const (
+	RuleUnknownEntryNode  = "unknown-entry-node"
+	RuleDanglingReference = "dangling-reference"
+	RuleDuplicateNodeID   = "duplicate-node-id"
+	RuleUnreachableNode   = "unreachable-node"
+	RuleRouteWithoutEdge  = "route-without-edge"
+	RuleDeadEndRouter     = "dead-end-router"
+	RuleCycleWithoutExit  = "cycle-without-exit"
+)
+
+// Finding is a problem found in a graph.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Node     string   `json:"node,omitempty"` // node the finding is about, if any
+	Message  string   `json:"message"`
+}
+
+// String formats the finding for logs and prompts.
+func (f Finding) String() string {
+	return fmt.Sprintf("%s [%s]: %s", f.Severity, f.Rule, f.Message)
+}
+
+// LintJSON parses graph JSON and lints it. Unlike Lint, it also finds node
+// IDs repeated in the nodes object or array, which parsing collapses.
+// Findings are ordered by rule and node.
+func LintJSON(data []byte) ([]Finding, error) {
+	graph, err := models.ParseGraph(data)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := append(duplicateKeys(data), Lint(graph)...)
+	sortFindings(findings)
+	return findings, nil
+}
+
+// Lint checks a parsed graph. Findings are ordered by rule and node.
+func Lint(graph *models.Graph) []Finding {
+	var findings []Finding
+	add := func(rule string, severity Severity, node, format string, args ...any) {
+		findings = append(findings, Finding{
+			Rule:     rule,
+			Severity: severity,
+			Node:     node,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	ids := graph.NodeIDs()
+
+	if graph.Node(graph.EntryNode) == nil {
+		add(RuleUnknownEntryNode, SeverityError, "", "entry node %q is not a node of the graph", graph.EntryNode)
+	}
+
+	// Nodes are keyed by ID, so keys and IDs must agree
+	owners := make(map[string][]string)
+	for _, key := range ids {
+		if node := graph.Node(key); node != nil {
+			owners[node.ID] = append(owners[node.ID], key)
+		}
+	}
+	for _, key := range ids {
+		node := graph.Node(key)
+		if node == nil || node.ID == key {
+			continue
+		}
+		if len(owners[node.ID]) > 1 || graph.Node(node.ID) != nil {
+			add(RuleDuplicateNodeID, SeverityError, key, "node %s has id %q, which another node already uses", key, node.ID)
+		} else {
+			add(RuleDuplicateNodeID, SeverityError, key, "node %s has id %q, which does not match its key", key, node.ID)
+		}
+	}
+
+	for i, edge := range graph.Edges {
+		if edge == nil {
+			continue
+		}
+		if graph.Node(edge.From) == nil {
+			add(RuleDanglingReference, SeverityError, edge.From, "edge %d starts at unknown node %q", i, edge.From)
+		}
+		if graph.Node(edge.To) == nil {
+			add(RuleDanglingReference, SeverityError, edge.From, "edge %d from %s points to unknown node %q", i, edge.From, edge.To)
+		}
+	}
+
+	for _, id := range ids {
+		node := graph.Node(id)
+		if node == nil || !node.IsRouter() {
+			continue
+		}
+
+		for _, target := range node.Targets() {
+			if graph.Node(target) == nil {
+				add(RuleDanglingReference, SeverityError, id, "router %s routes to unknown node %q", id, target)
+				continue
+			}
+			if !hasEdge(graph, id, target) {
+				add(RuleRouteWithoutEdge, SeverityWarning, id, "router %s routes to %s, but no edge connects them", id, target)
+			}
+		}
+
+		if len(graph.Successors(id)) == 0 {
+			add(RuleDeadEndRouter, SeverityError, id, "router %s has no routes, default route or outgoing edges", id)
+		}
+	}
+
+	reached := graph.Reachable()
+	for _, id := range ids {
+		if !reached[id] {
+			add(RuleUnreachableNode, SeverityWarning, id, "node %s is not reachable from the entry node", id)
+		}
+	}
+
+	for _, cycle := range closedCycles(graph) {
+		add(RuleCycleWithoutExit, SeverityError, cycle[0], "nodes %s form a cycle with no way out", strings.Join(cycle, ", "))
+	}
+
+	sortFindings(findings)
+	return findings
+}
+
+// Errors returns the findings of error severity.
+func Errors(findings []Finding) []Finding {
+	return filter(findings, SeverityError)
+}
+
+// Warnings returns the findings of warning severity.
+func Warnings(findings []Finding) []Finding {
+	return filter(findings, SeverityWarning)
+}
+
+// Strings formats findings for logs and prompts.
+func Strings(findings []Finding) []string {
+	result := make([]string, 0, len(findings))
+	for _, f := range findings {
+		result = append(result, f.String())
+	}
+	return result
+}
+
+// sortFindings orders findings by rule and node, keeping the order of
+// findings about the same node.
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Rule != findings[j].Rule {
+			return findings[i].Rule < findings[j].Rule
+		}
+		return findings[i].Node < findings[j].Node
+	})
+}
+
+func filter(findings []Finding, severity Severity) []Finding {
+	var result []Finding
+	for _, f := range findings {
+		if f.Severity == severity {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// hasEdge reports whether an edge leads from one node to another.
+func hasEdge(graph *models.Graph, from, to string) bool {
+	for _, edge := range graph.Edges {
+		if edge != nil && edge.From == from && edge.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// closedCycles returns the cycles no node of which leads out of the cycle,
+// so that execution entering them never ends. Each cycle is listed as its
+// sorted node IDs.
+func closedCycles(graph *models.Graph) [][]string {
+	// Tarjan's strongly connected components
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		lowlink[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, next := range graph.Successors(id) {
+			if graph.Node(next) == nil {
+				continue
+			}
+			if _, seen := index[next]; !seen {
+				visit(next)
+				lowlink[id] = min(lowlink[id], lowlink[next])
+			} else if onStack[next] {
+				lowlink[id] = min(lowlink[id], index[next])
+			}
+		}
+
+		if lowlink[id] == index[id] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, id := range graph.NodeIDs() {
+		if _, seen := index[id]; !seen {
+			visit(id)
+		}
+	}
+
+	var cycles [][]string
+	for _, component := range components {
+		members := make(map[string]bool, len(component))
+		for _, id := range component {
+			members[id] = true
+		}
+
+		// A single node is a cycle only if it leads to itself
+		if len(component) == 1 && !slices.Contains(graph.Successors(component[0]), component[0]) {
+			continue
+		}
+
+		closed := true
+		for _, id := range component {
+			for _, next := range graph.Successors(id) {
+				if !members[next] {
+					closed = false
+				}
+			}
+		}
+		if closed {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	return cycles
+}
+
+// duplicateKeys finds node IDs defined more than once in the nodes object
+// or array, which parsing collapses into the last definition.
+func duplicateKeys(data []byte) []Finding {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	var ids []string
+	nodes := bytes.TrimSpace(fields["nodes"])
+	switch {
+	case len(nodes) == 0:
+		return nil
+
+	case nodes[0] == '[':
+		var list []struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(nodes, &list); err != nil {
+			return nil
+		}
+		for _, node := range list {
+			ids = append(ids, node.ID)
+		}
+
+	case nodes[0] == '{':
+		dec := json.NewDecoder(bytes.NewReader(nodes))
+		if _, err := dec.Token(); err != nil {
+			return nil
+		}
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				break
+			}
+			key, _ := token.(string)
+			ids = append(ids, key)
+
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				break
+			}
+		}
+	}
+
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			findings = append(findings, Finding{
+				Rule:     RuleDuplicateNodeID,
+				Severity: SeverityError,
+				Node:     id,
+				Message:  fmt.Sprintf("node %s is defined more than once; only the last definition is kept", id),
+			})
+		}
+		seen[id] = true
+	}
+
+	return findings
+}
@@ -0,0 +1,199 @@
+package lint
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// summarize lists findings as "severity rule node" in order.
+func summarize(findings []Finding) []string {
+	result := make([]string, 0, len(findings))
+	for _, f := range findings {
+		result = append(result, strings.TrimSpace(string(f.Severity)+" "+f.Rule+" "+f.Node))
+	}
+	return result
+}
+
+func TestLintJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   string
+		want    []string
+		message string // substring of the first finding's message, if set
+	}{
+		{
+			name: "clean graph",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "b", "type": "executor"}},
+				"edges": [{"from": "a", "to": "b"}]}`,
+			want: []string{},
+		},
+		{
+			name: "unknown entry node",
+			graph: `{"id": "g", "entry_node": "missing",
+				"nodes": {"a": {"id": "a", "type": "executor"}}}`,
+			want:    []string{"error unknown-entry-node", "warning unreachable-node a"},
+			message: `entry node "missing"`,
+		},
+		{
+			name: "edge to unknown node",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}},
+				"edges": [{"from": "a", "to": "ghost"}]}`,
+			want:    []string{"error dangling-reference a"},
+			message: `edge 0 from a points to unknown node "ghost"`,
+		},
+		{
+			name: "edge from unknown node",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}},
+				"edges": [{"from": "ghost", "to": "a"}]}`,
+			want:    []string{"error dangling-reference ghost"},
+			message: `edge 0 starts at unknown node "ghost"`,
+		},
+		{
+			name: "route to unknown node",
+			graph: `{"id": "g", "entry_node": "r",
+				"nodes": {
+					"r": {"id": "r", "type": "router", "routes": [{"target": "ghost"}], "default_route": "b"},
+					"b": {"id": "b", "type": "executor"}},
+				"edges": [{"from": "r", "to": "b"}]}`,
+			want:    []string{"error dangling-reference r"},
+			message: `router r routes to unknown node "ghost"`,
+		},
+		{
+			name: "node ID used by another node",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "a", "type": "executor"}},
+				"edges": [{"from": "a", "to": "b"}]}`,
+			want:    []string{"error duplicate-node-id b"},
+			message: "which another node already uses",
+		},
+		{
+			name: "node ID not matching its key",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "c", "type": "executor"}},
+				"edges": [{"from": "a", "to": "b"}]}`,
+			want:    []string{"error duplicate-node-id b"},
+			message: "which does not match its key",
+		},
+		{
+			name: "key repeated in nodes object",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "a": {"id": "a", "type": "executor"}}}`,
+			want:    []string{"error duplicate-node-id a"},
+			message: "node a is defined more than once",
+		},
+		{
+			name: "ID repeated in nodes array",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": [{"id": "a", "type": "executor"}, {"id": "b", "type": "executor"}, {"id": "a", "type": "executor"}],
+				"edges": [{"from": "a", "to": "b"}]}`,
+			want:    []string{"error duplicate-node-id a"},
+			message: "node a is defined more than once",
+		},
+		{
+			name: "repeated key sorted among other findings",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "b", "type": "executor"}, "b": {"id": "b", "type": "executor"}},
+				"edges": [{"from": "a", "to": "b"}, {"from": "a", "to": "ghost"}]}`,
+			want: []string{"error dangling-reference a", "error duplicate-node-id b"},
+		},
+		{
+			name: "unreachable node",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "b", "type": "executor"}}}`,
+			want:    []string{"warning unreachable-node b"},
+			message: "node b is not reachable",
+		},
+		{
+			name: "route without edge",
+			graph: `{"id": "g", "entry_node": "r",
+				"nodes": {
+					"r": {"id": "r", "type": "router", "routes": [{"target": "b"}], "default_route": "c"},
+					"b": {"id": "b", "type": "executor"},
+					"c": {"id": "c", "type": "executor"}},
+				"edges": [{"from": "r", "to": "c"}]}`,
+			want:    []string{"warning route-without-edge r"},
+			message: "router r routes to b, but no edge connects them",
+		},
+		{
+			name: "dead-end router",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "r": {"id": "r", "type": "router"}},
+				"edges": [{"from": "a", "to": "r"}]}`,
+			want:    []string{"error dead-end-router r"},
+			message: "router r has no routes",
+		},
+		{
+			name: "cycle without exit",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "b", "type": "executor"}, "c": {"id": "c", "type": "executor"}},
+				"edges": [{"from": "a", "to": "b"}, {"from": "b", "to": "c"}, {"from": "c", "to": "b"}]}`,
+			want:    []string{"error cycle-without-exit b"},
+			message: "nodes b, c form a cycle",
+		},
+		{
+			name: "node leading to itself",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "b", "type": "executor"}},
+				"edges": [{"from": "a", "to": "b"}, {"from": "b", "to": "b"}]}`,
+			want: []string{"error cycle-without-exit b"},
+		},
+		{
+			name: "cycle with exit",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {
+					"a": {"id": "a", "type": "executor"},
+					"r": {"id": "r", "type": "router", "routes": [{"target": "a"}], "default_route": "done"},
+					"done": {"id": "done", "type": "executor"}},
+				"edges": [{"from": "a", "to": "r"}, {"from": "r", "to": "a"}, {"from": "r", "to": "done"}]}`,
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := LintJSON([]byte(tt.graph))
+			if err != nil {
+				t.Fatalf("LintJSON() error = %v", err)
+			}
+
+			if got := summarize(findings); !slices.Equal(got, tt.want) {
+				t.Fatalf("LintJSON() = %v, want %v", got, tt.want)
+			}
+			if tt.message != "" && !strings.Contains(findings[0].Message, tt.message) {
+				t.Errorf("message = %q, want it to contain %q", findings[0].Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestLintJSONInvalid(t *testing.T) {
+	if _, err := LintJSON([]byte(`{"id": "g", "nodes": `)); err == nil {
+		t.Error("LintJSON() error = nil, want an error")
+	}
+}
+
+func TestFilters(t *testing.T) {
+	findings := []Finding{
+		{Rule: RuleDanglingReference, Severity: SeverityError, Node: "a", Message: "edge 0 from a points to unknown node \"x\""},
+		{Rule: RuleUnreachableNode, Severity: SeverityWarning, Node: "b", Message: "node b is not reachable from the entry node"},
+	}
+
+	if got := summarize(Errors(findings)); !slices.Equal(got, []string{"error dangling-reference a"}) {
+		t.Errorf("Errors() = %v", got)
+	}
+	if got := summarize(Warnings(findings)); !slices.Equal(got, []string{"warning unreachable-node b"}) {
+		t.Errorf("Warnings() = %v", got)
+	}
+
+	want := []string{
+		`error [dangling-reference]: edge 0 from a points to unknown node "x"`,
+		"warning [unreachable-node]: node b is not reachable from the entry node",
+	}
+	if got := Strings(findings); !slices.Equal(got, want) {
+		t.Errorf("Strings() = %v, want %v", got, want)
+	}
+}
@@ -12,11 +12,13 @@ import (
 
 // Prompter builds LLM prompts for graph planning.
 type Prompter struct {
-	promptPath         string
-	systemPrompt       string
-	planningTemplate   string
-	errorFixingTemplate string
-	logger             *zap.Logger
+	promptPath             string
+	systemPrompt           string
+	planningTemplate       string
+	errorFixingTemplate    string
+	repairFeedbackTemplate string
+	continuationPrompt     string
+	logger                 *zap.Logger
 }
 
 // NewPrompter creates a new prompter.
@@ -39,11 +41,15 @@ func (p *Prompter) loadPrompts() {
 		p.systemPrompt = p.loadPromptFile("system-prompt.txt", defaultSystemPrompt)
 		p.planningTemplate = p.loadPromptFile("task-planning.txt", defaultPlanningTemplate)
 		p.errorFixingTemplate = p.loadPromptFile("error-fixing.txt", defaultErrorFixingTemplate)
+		p.repairFeedbackTemplate = p.loadPromptFile("repair-feedback.txt", defaultRepairFeedbackTemplate)
+		p.continuationPrompt = p.loadPromptFile("continuation.txt", defaultContinuationPrompt)
 	} else {
 		// Use defaults
 		p.systemPrompt = defaultSystemPrompt
 		p.planningTemplate = defaultPlanningTemplate
 		p.errorFixingTemplate = defaultErrorFixingTemplate
+		p.repairFeedbackTemplate = defaultRepairFeedbackTemplate
+		p.continuationPrompt = defaultContinuationPrompt
 	}
 }
 
@@ -70,12 +76,16 @@ func (p *Prompter) GetSystemPrompt() string {
 	return p.systemPrompt
 }
 
-// BuildPlanningPrompt builds the initial planning prompt.
+// BuildPlanningPrompt builds the initial planning prompt. If the prompt
+// exceeds maxTokens (0 means unlimited), the context is summarized and then
+// the analysis dropped to make it fit.
 func (p *Prompter) BuildPlanningPrompt(
 	task string,
+	taskContext map[string]any,
 	analysis *models.TaskAnalysis,
 	schemas map[string]string,
 	constraints *models.Constraints,
+	maxTokens int,
 ) (string, error) {
 	prompt := p.planningTemplate
 
@@ -100,7 +110,6 @@ Task Analysis:
 			analysis.Intent,
 		)
 	}
-	prompt = strings.ReplaceAll(prompt, "{{ANALYSIS}}", analysisStr)
 
 	// Add constraints if available
 	constraintsStr := ""
@@ -122,21 +131,40 @@ Constraints:
 	schemasStr := "See the graph schema documentation for the full schema specification."
 	prompt = strings.ReplaceAll(prompt, "{{SCHEMAS}}", schemasStr)
 
-	return prompt, nil
+	// Optional sections, in the order they are trimmed
+	sections := []*promptSection{
+		{
+			name:        "context",
+			placeholder: "{{CONTEXT}}",
+			content:     formatContext(taskContext),
+			shorten: func(maxTokens int) string {
+				return summarizeContext(taskContext, maxTokens)
+			},
+		},
+		{
+			name:        "analysis",
+			placeholder: "{{ANALYSIS}}",
+			content:     analysisStr,
+		},
+	}
+
+	return p.fitPrompt(prompt, sections, maxTokens)
 }
 
-// BuildErrorFixingPrompt builds a prompt for fixing validation errors.
+// BuildErrorFixingPrompt builds a prompt for fixing validation errors. If the
+// prompt exceeds maxTokens (0 means unlimited), the previous graph is
+// truncated to make it fit.
 func (p *Prompter) BuildErrorFixingPrompt(
 	task string,
 	previousGraph string,
 	validationErrors []string,
 	attempt int,
+	maxTokens int,
 ) (string, error) {
 	prompt := p.errorFixingTemplate
 
 	// Replace placeholders
 	prompt = strings.ReplaceAll(prompt, "{{TASK}}", task)
-	prompt = strings.ReplaceAll(prompt, "{{PREVIOUS_GRAPH}}", previousGraph)
 	prompt = strings.ReplaceAll(prompt, "{{ATTEMPT}}", fmt.Sprintf("%d", attempt))
 
 	// Format validation errors
@@ -146,7 +174,42 @@ func (p *Prompter) BuildErrorFixingPrompt(
 	}
 	prompt = strings.ReplaceAll(prompt, "{{VALIDATION_ERRORS}}", errorsStr)
 
-	return prompt, nil
+	sections := []*promptSection{
+		{
+			name:        "previous_graph",
+			placeholder: "{{PREVIOUS_GRAPH}}",
+			content:     previousGraph,
+			shorten: func(maxTokens int) string {
+				return truncateGraph(previousGraph, maxTokens)
+			},
+		},
+	}
+
+	return p.fitPrompt(prompt, sections, maxTokens)
+}
+
+// BuildRepairFeedback builds the feedback message sent after a failed attempt
+// in a multi-turn repair conversation. The original request and the failed
+// graph are already part of the conversation.
+func (p *Prompter) BuildRepairFeedback(validationErrors []string, attempt int) string {
+	prompt := p.repairFeedbackTemplate
+
+	prompt = strings.ReplaceAll(prompt, "{{ATTEMPT}}", fmt.Sprintf("%d", attempt))
+
+	// Format validation errors
+	errorsStr := ""
+	for i, err := range validationErrors {
+		errorsStr += fmt.Sprintf("%d. %s\n", i+1, err)
+	}
+	prompt = strings.ReplaceAll(prompt, "{{VALIDATION_ERRORS}}", errorsStr)
+
+	return prompt
+}
+
+// GetContinuationPrompt returns the prompt asking the LLM to continue a
+// response that was cut off at the output token limit.
+func (p *Prompter) GetContinuationPrompt() string {
+	return p.continuationPrompt
 }
 
 // Default prompts
@@ -172,6 +235,8 @@ const defaultPlanningTemplate = `Generate an execution graph for the following t
 
 {{TASK}}
 
+{{CONTEXT}}
+
 {{ANALYSIS}}
 
 {{CONSTRAINTS}}
@@ -199,3 +264,18 @@ Generate a corrected graph that fixes these validation errors while maintaining
 Respond with:
 1. A "reasoning" section explaining your fixes
 2. A "graph" section containing the corrected JSON graph`
+
+const defaultRepairFeedbackTemplate = `Your graph (attempt {{ATTEMPT}}) did not pass validation.
+
+Validation Errors:
+{{VALIDATION_ERRORS}}
+
+Fix these errors while maintaining the intent of the original task.
+
+Respond with:
+1. A "reasoning" section explaining your fixes
+2. A "graph" section containing the complete corrected JSON graph`
+
+const defaultContinuationPrompt = `Your previous response was cut off because it reached the output token limit.
+
+Continue exactly where it stopped, starting with the next character. Do not repeat any earlier output, do not restart the response and do not add explanations or code fences.`
@@ -0,0 +1,51 @@
+package planner
+
+import (
+	"github.com/aescanero/dago-node-planner/pkg/models"
+)
+
+// defaultRoute names the decision of plans matching no routing table entry.
+const defaultRoute = "default"
+
+// routeTask picks the generation model and limits of a plan from the
+// routing table (planning.routes) by the analyzed task complexity. Request
+// constraints take precedence over the route, which takes precedence over
+// the defaults; the model and output tokens the route leaves unset come
+// from the phase profiles. It returns nil if no routing table is configured.
+func (s *Service) routeTask(analysis *models.TaskAnalysis, constraints *models.Constraints) *models.RouteDecision {
+	if len(s.config.Routes) == 0 {
+		return nil
+	}
+
+	decision := &models.RouteDecision{Route: defaultRoute}
+	if analysis != nil {
+		decision.Complexity = analysis.Complexity
+	}
+
+	route, ok := s.config.Routes[string(decision.Complexity)]
+	if ok {
+		decision.Route = string(decision.Complexity)
+	}
+
+	// The generation and repair profiles stay in force unless the route
+	// overrides them
+	decision.Model = route.Model
+	decision.MaxTokens = route.MaxTokens
+
+	decision.MaxIterations = s.config.MaxIterations
+	if route.MaxIterations > 0 {
+		decision.MaxIterations = route.MaxIterations
+	}
+	decision.MaxTokensBudget = route.MaxTokensBudget
+
+	if constraints != nil {
+		if constraints.MaxIterations > 0 {
+			decision.MaxIterations = constraints.MaxIterations
+		}
+		if constraints.MaxTokensBudget > 0 {
+			decision.MaxTokensBudget = constraints.MaxTokensBudget
+		}
+	}
+
+	return decision
+}
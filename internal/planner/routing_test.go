@@ -0,0 +1,171 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/schema"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestRouteTask(t *testing.T) {
+	routes := map[string]config.ComplexityRoute{
+		"simple":  {Model: "small-model", MaxIterations: 2, MaxTokens: 2048},
+		"complex": {MaxIterations: 5, MaxTokensBudget: 100000},
+	}
+
+	tests := []struct {
+		name        string
+		routes      map[string]config.ComplexityRoute
+		analysis    *models.TaskAnalysis
+		constraints *models.Constraints
+		want        *models.RouteDecision
+	}{
+		{
+			name:     "no routing table",
+			analysis: &models.TaskAnalysis{Complexity: "simple"},
+		},
+		{
+			name:     "route sets the model and limits",
+			routes:   routes,
+			analysis: &models.TaskAnalysis{Complexity: "simple"},
+			want: &models.RouteDecision{
+				Complexity: "simple", Route: "simple", Model: "small-model", MaxIterations: 2, MaxTokens: 2048,
+			},
+		},
+		{
+			name:     "route keeps the profile model and output tokens",
+			routes:   routes,
+			analysis: &models.TaskAnalysis{Complexity: "complex"},
+			want: &models.RouteDecision{
+				Complexity: "complex", Route: "complex", MaxIterations: 5, MaxTokensBudget: 100000,
+			},
+		},
+		{
+			name:     "complexity without a route",
+			routes:   routes,
+			analysis: &models.TaskAnalysis{Complexity: "moderate"},
+			want:     &models.RouteDecision{Complexity: "moderate", Route: defaultRoute, MaxIterations: 3},
+		},
+		{
+			name:   "task not analyzed",
+			routes: routes,
+			want:   &models.RouteDecision{Route: defaultRoute, MaxIterations: 3},
+		},
+		{
+			name:        "constraints take precedence over the route",
+			routes:      routes,
+			analysis:    &models.TaskAnalysis{Complexity: "complex"},
+			constraints: &models.Constraints{MaxIterations: 1, MaxTokensBudget: 5000},
+			want: &models.RouteDecision{
+				Complexity: "complex", Route: "complex", MaxIterations: 1, MaxTokensBudget: 5000,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				config: &config.PlanningConfig{MaxIterations: 3, Routes: tt.routes},
+				logger: zap.NewNop(),
+			}
+
+			got := s.routeTask(tt.analysis, tt.constraints)
+			switch {
+			case got == nil || tt.want == nil:
+				if got != tt.want {
+					t.Errorf("routeTask() = %+v, want %+v", got, tt.want)
+				}
+			case *got != *tt.want:
+				t.Errorf("routeTask() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+// newRoutedService creates a Service answering from testdata/fake-script.yaml,
+// whose analysis finds a simple task and whose first graph needs a repair.
+func newRoutedService(t *testing.T, route config.ComplexityRoute) *Service {
+	t.Helper()
+	logger := zap.NewNop()
+
+	fake, err := llm.NewFakeClient(llm.FakeConfig{ScriptPath: "testdata/fake-script.yaml"}, logger)
+	if err != nil {
+		t.Fatalf("NewFakeClient() error = %v", err)
+	}
+
+	client := llm.NewClient(fake, &config.LLMConfig{
+		Provider:    "fake",
+		Model:       "fake-planner",
+		MaxTokens:   4096,
+		RetryConfig: config.RetryConfig{MaxAttempts: 1},
+		Profiles: map[string]config.PhaseProfile{
+			"generation": {Model: "generation-model", MaxTokens: 3000},
+			"repair":     {Model: "repair-model", MaxTokens: 2000},
+		},
+	}, logger)
+
+	validator, err := schema.NewValidator()
+	if err != nil {
+		t.Fatalf("schema.NewValidator() error = %v", err)
+	}
+
+	return NewService(client, validator, &config.PlanningConfig{
+		MaxIterations:    3,
+		MaxNodes:         50,
+		EnableValidation: true,
+		EnableAnalysis:   true,
+		EnableLint:       true,
+		Samples:          1,
+		Routes:           map[string]config.ComplexityRoute{"simple": route},
+	}, logger)
+}
+
+func TestRoutingProfilePrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      config.ComplexityRoute
+		wantModels []string // per iteration: generation, then repair
+	}{
+		{
+			name:       "route without a model keeps the phase profiles",
+			route:      config.ComplexityRoute{MaxIterations: 2},
+			wantModels: []string{"generation-model", "repair-model"},
+		},
+		{
+			name:       "route model overrides both phases",
+			route:      config.ComplexityRoute{Model: "routed-model"},
+			wantModels: []string{"routed-model", "routed-model"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newRoutedService(t, tt.route)
+
+			resp, err := service.Plan(context.Background(), &models.PlanRequest{
+				Task: "Send a welcome email to the new user",
+			})
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+
+			if resp.Metadata.Route == nil || resp.Metadata.Route.Route != "simple" || resp.Metadata.Route.Model != tt.route.Model {
+				t.Errorf("Route = %+v, want the simple route with model %q", resp.Metadata.Route, tt.route.Model)
+			}
+
+			reports := resp.IterationReports
+			if len(reports) != len(tt.wantModels) {
+				t.Fatalf("IterationReports = %+v, want %d iterations", reports, len(tt.wantModels))
+			}
+			for i, want := range tt.wantModels {
+				if reports[i].Model != want {
+					t.Errorf("iteration %d (%s) model = %q, want %q", i+1, reports[i].Phase, reports[i].Model, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/pkg/models"
+	"go.uber.org/zap"
+)
+
+// maxSamples caps the number of candidates of self-consistency sampling.
+const maxSamples = 10
+
+// candidate is the outcome of one sampled generation.
+type candidate struct {
+	index int
+	resp  *GenerateResponse
+	err   error
+	score graphScore
+}
+
+// generateSamples generates req.Samples candidate graphs concurrently at
+// req.SampleTemperature, scores the valid ones and returns the best along
+// with summaries of the others.
+func (g *Generator) generateSamples(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	candidates := make([]*candidate, min(req.Samples, maxSamples))
+
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := g.generate(ctx, req, true)
+			candidates[i] = &candidate{index: i + 1, resp: resp, err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	maxNodes := g.config.MaxNodes
+	if req.Constraints != nil && req.Constraints.MaxNodes > 0 {
+		maxNodes = req.Constraints.MaxNodes
+	}
+
+	var best *candidate
+	continuations := 0
+	for _, c := range candidates {
+		var budgetErr *BudgetExhaustedError
+		if errors.As(c.err, &budgetErr) {
+			continuations += budgetErr.Continuations
+		}
+		if c.err != nil {
+			g.logger.Debug("candidate graph failed",
+				zap.Int("candidate", c.index),
+				zap.Error(c.err),
+			)
+			continue
+		}
+
+		continuations += c.resp.Continuations
+		c.score = scoreGraph(c.resp.Graph, c.resp.Iterations, maxNodes, req.Constraints)
+
+		if best == nil || c.score.score > best.score.score {
+			best = c
+		}
+	}
+
+	if best == nil {
+		return nil, samplingError(candidates)
+	}
+
+	resp := *best.resp
+	resp.Continuations = continuations
+	resp.Samples = len(candidates)
+	resp.Score = best.score.score
+	for _, c := range candidates {
+		if c != best {
+			resp.Alternatives = append(resp.Alternatives, c.summary())
+		}
+	}
+
+	g.logger.Debug("selected candidate graph",
+		zap.Int("candidate", best.index),
+		zap.Float64("score", best.score.score),
+		zap.Int("samples", len(candidates)),
+	)
+
+	return &resp, nil
+}
+
+// summary summarizes the candidate for the plan response.
+func (c *candidate) summary() models.CandidateSummary {
+	if c.err != nil {
+		return models.CandidateSummary{
+			Candidate: c.index,
+			Error:     c.err.Error(),
+		}
+	}
+
+	return models.CandidateSummary{
+		Candidate:  c.index,
+		Valid:      true,
+		Score:      c.score.score,
+		NodeCount:  c.score.nodeCount,
+		Iterations: c.resp.Iterations,
+		Notes:      c.score.notes,
+		Reasoning:  c.resp.Reasoning,
+	}
+}
+
+// samplingError picks the error to report when no candidate succeeded.
+// Budget and context window errors take precedence so that callers can
+// handle them as they would without sampling. Of the budget errors, the one
+// of the candidate that got furthest is reported, carrying its best graph.
+func samplingError(candidates []*candidate) error {
+	var best *BudgetExhaustedError
+	for _, c := range candidates {
+		var budgetErr *BudgetExhaustedError
+		if !errors.As(c.err, &budgetErr) {
+			continue
+		}
+		if best == nil || furtherThan(budgetErr, best) {
+			best = budgetErr
+		}
+	}
+	if best != nil {
+		return best
+	}
+	for _, c := range candidates {
+		if errors.Is(c.err, llm.ErrContextWindowExceeded) {
+			return c.err
+		}
+	}
+	return fmt.Errorf("none of %d candidate graphs was valid: %w", len(candidates), candidates[0].err)
+}
+
+// furtherThan reports whether the candidate that ran out of budget with a
+// got further than the one with b: it has a graph and b does not, or more
+// iterations.
+func furtherThan(a, b *BudgetExhaustedError) bool {
+	if (a.GraphJSON != "") != (b.GraphJSON != "") {
+		return a.GraphJSON != ""
+	}
+	return a.Iterations > b.Iterations
+}
@@ -0,0 +1,94 @@
+package planner
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aescanero/dago-node-planner/internal/planner/lint"
+	"github.com/aescanero/dago-node-planner/pkg/models"
+)
+
+// Score deductions of candidate graphs. Candidates start at baseScore and
+// the highest score wins; ties go to the earlier candidate.
+const (
+	baseScore             = 100.0
+	nodePenalty           = 1.0  // per node, favouring minimal graphs
+	maxNodesPenalty       = 50.0 // graph exceeds the node limit
+	unavailableToolCost   = 10.0 // per tool call of a tool not listed as available
+	unpreferredModeCost   = 5.0  // per executor whose type is not a preferred mode
+	unreachableNodeCost   = 10.0 // per node not reachable from the entry node
+	danglingReferenceCost = 10.0 // per edge or route pointing at an unknown node
+	routeWithoutEdgeCost  = 3.0  // per route target no edge leads to
+	lintErrorCost         = 10.0 // per other lint error
+	lintWarningCost       = 5.0  // per other lint warning
+	iterationPenalty      = 2.0  // per refinement iteration beyond the first
+)
+
+// findingCosts are the deductions for lint findings, by rule. Findings of
+// other rules cost lintErrorCost or lintWarningCost by severity.
+var findingCosts = map[string]float64{
+	lint.RuleDanglingReference: danglingReferenceCost,
+	lint.RuleUnreachableNode:   unreachableNodeCost,
+	lint.RuleRouteWithoutEdge:  routeWithoutEdgeCost,
+}
+
+// graphScore is the score of a candidate graph with the reasons for its
+// deductions.
+type graphScore struct {
+	score     float64
+	nodeCount int
+	notes     []string
+}
+
+// scoreGraph scores a valid graph on its size, its adherence to the
+// constraints and its lint findings. maxNodes is the node limit in force.
+func scoreGraph(graph *models.Graph, iterations, maxNodes int, constraints *models.Constraints) graphScore {
+	s := graphScore{score: baseScore, nodeCount: len(graph.Nodes)}
+
+	deduct := func(points float64, format string, args ...any) {
+		s.score -= points
+		s.notes = append(s.notes, fmt.Sprintf(format, args...))
+	}
+
+	s.score -= nodePenalty * float64(len(graph.Nodes))
+	if maxNodes > 0 && len(graph.Nodes) > maxNodes {
+		deduct(maxNodesPenalty, "%d nodes exceed the limit of %d", len(graph.Nodes), maxNodes)
+	}
+
+	ids := graph.NodeIDs()
+
+	if constraints != nil {
+		for _, id := range ids {
+			node := graph.Node(id)
+			if node == nil || !node.IsExecutor() {
+				continue
+			}
+
+			if len(constraints.PreferredModes) > 0 && !slices.Contains(constraints.PreferredModes, node.ExecutorType) {
+				deduct(unpreferredModeCost, "node %s uses non-preferred executor type %q", id, node.ExecutorType)
+			}
+
+			tool := node.ToolName()
+			if tool != "" && len(constraints.AvailableTools) > 0 && !slices.Contains(constraints.AvailableTools, tool) {
+				deduct(unavailableToolCost, "node %s calls unavailable tool %q", id, tool)
+			}
+		}
+	}
+
+	for _, finding := range lint.Lint(graph) {
+		cost, ok := findingCosts[finding.Rule]
+		if !ok {
+			cost = lintWarningCost
+			if finding.Severity == lint.SeverityError {
+				cost = lintErrorCost
+			}
+		}
+		deduct(cost, "%s", finding.Message)
+	}
+
+	if iterations > 1 {
+		deduct(iterationPenalty*float64(iterations-1), "needed %d iterations", iterations)
+	}
+
+	return s
+}
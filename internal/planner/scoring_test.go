@@ -0,0 +1,90 @@
+package planner
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aescanero/dago-node-planner/pkg/models"
+)
+
+func TestScoreGraph(t *testing.T) {
+	graph, err := models.ParseGraph([]byte(`{"id": "g", "entry_node": "a",
+		"nodes": {
+			"a": {"id": "a", "type": "executor", "executor_type": "tool", "config": {"tool_name": "search"}},
+			"r": {"id": "r", "type": "router", "routes": [{"target": "ghost"}], "default_route": "a"},
+			"b": {"id": "b", "type": "executor", "executor_type": "llm"}},
+		"edges": [{"from": "a", "to": "r"}, {"from": "r", "to": "a"}]}`))
+	if err != nil {
+		t.Fatalf("ParseGraph() error = %v", err)
+	}
+
+	got := scoreGraph(graph, 2, 2, &models.Constraints{
+		AvailableTools: []string{"email"},
+		PreferredModes: []string{"tool"},
+	})
+
+	want := baseScore - 3*nodePenalty - maxNodesPenalty - unpreferredModeCost - unavailableToolCost -
+		danglingReferenceCost - unreachableNodeCost - iterationPenalty
+	if got.score != want || got.nodeCount != 3 {
+		t.Errorf("scoreGraph() = %v with %d nodes, want %v with 3", got.score, got.nodeCount, want)
+	}
+
+	notes := []string{
+		"3 nodes exceed the limit of 2",
+		`node a calls unavailable tool "search"`,
+		`node b uses non-preferred executor type "llm"`,
+		`router r routes to unknown node "ghost"`,
+		"node b is not reachable from the entry node",
+		"needed 2 iterations",
+	}
+	if !slices.Equal(got.notes, notes) {
+		t.Errorf("notes = %q, want %q", got.notes, notes)
+	}
+}
+
+func TestScoreGraphLintFindings(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph string
+		cost  float64
+		note  string
+	}{
+		{
+			name: "route without edge",
+			graph: `{"id": "g", "entry_node": "r",
+				"nodes": {
+					"r": {"id": "r", "type": "router", "routes": [{"target": "b"}], "default_route": "c"},
+					"b": {"id": "b", "type": "executor"},
+					"c": {"id": "c", "type": "executor"}},
+				"edges": [{"from": "r", "to": "c"}]}`,
+			cost: routeWithoutEdgeCost,
+			note: "router r routes to b, but no edge connects them",
+		},
+		{
+			name: "lint error without a cost of its own",
+			graph: `{"id": "g", "entry_node": "a",
+				"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "b", "type": "executor"}, "c": {"id": "c", "type": "executor"}},
+				"edges": [{"from": "a", "to": "b"}, {"from": "b", "to": "c"}, {"from": "c", "to": "b"}]}`,
+			cost: lintErrorCost,
+			note: "nodes b, c form a cycle with no way out",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := models.ParseGraph([]byte(tt.graph))
+			if err != nil {
+				t.Fatalf("ParseGraph() error = %v", err)
+			}
+
+			got := scoreGraph(graph, 1, 0, nil)
+
+			if want := baseScore - 3*nodePenalty - tt.cost; got.score != want {
+				t.Errorf("scoreGraph() = %v, want %v", got.score, want)
+			}
+			if !slices.Equal(got.notes, []string{tt.note}) {
+				t.Errorf("notes = %q, want [%q]", got.notes, tt.note)
+			}
+		})
+	}
+}
@@ -3,17 +3,28 @@ package planner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/aescanero/dago-libs/pkg/schema"
 	"github.com/aescanero/dago-node-planner/internal/config"
 	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/internal/planner/lint"
 	"github.com/aescanero/dago-node-planner/pkg/models"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// Planning phases used to label LLM calls in the per-plan usage breakdown
+// and to select their profiles (llm.profiles).
+const (
+	PhaseAnalysis     = "analysis"
+	PhaseGeneration   = "generation"
+	PhaseRepair       = "repair"
+	PhaseContinuation = "continuation"
+)
+
 // Service is the main planning service that orchestrates graph generation.
 type Service struct {
 	llmClient       *llm.Client
@@ -34,8 +45,8 @@ func NewService(
 	analyzer := NewAnalyzer(llmClient, logger)
 
 	prompter := NewPrompter(cfg.PromptPath, logger)
-	extractor := NewExtractor(logger)
-	iterator := NewIterator(cfg.MaxIterations, logger)
+	extractor := NewExtractor(schemaValidator, logger)
+	iterator := NewIterator(cfg.MaxIterations, NewStrategies(cfg.Strategies), logger)
 
 	generator := NewGenerator(
 		llmClient,
@@ -43,6 +54,7 @@ func NewService(
 		extractor,
 		schemaValidator,
 		iterator,
+		cfg,
 		logger,
 	)
 
@@ -61,6 +73,16 @@ func (s *Service) Plan(ctx context.Context, req *models.PlanRequest) (*models.Pl
 	startTime := time.Now()
 	planID := uuid.New().String()
 
+	// Track the LLM usage of this plan only
+	usage := llm.NewPlanUsage()
+	if req.Constraints != nil {
+		usage.SetBudget(llm.Budget{
+			MaxTokens: req.Constraints.MaxTokensBudget,
+			MaxCost:   req.Constraints.MaxCost,
+		})
+	}
+	ctx = llm.WithPlanUsage(ctx, usage)
+
 	s.logger.Info("starting graph planning",
 		zap.String("plan_id", planID),
 		zap.String("task", req.Task),
@@ -71,7 +93,12 @@ func (s *Service) Plan(ctx context.Context, req *models.PlanRequest) (*models.Pl
 	if !req.SkipAnalysis && s.config.EnableAnalysis {
 		var err error
 		analysis, err = s.analyzer.Analyze(ctx, req.Task)
-		if err != nil {
+		if errors.Is(err, llm.ErrBudgetExhausted) {
+			s.logger.Info("skipping task analysis, not enough budget",
+				zap.String("plan_id", planID),
+				zap.Error(err),
+			)
+		} else if err != nil {
 			s.logger.Warn("task analysis failed, continuing without it",
 				zap.Error(err),
 			)
@@ -86,33 +113,93 @@ func (s *Service) Plan(ctx context.Context, req *models.PlanRequest) (*models.Pl
 
 	// Step 2: Generate graph
 	genReq := &GenerateRequest{
-		Task:        req.Task,
-		Context:     req.Context,
-		Analysis:    analysis,
-		Constraints: req.Constraints,
+		Task:              req.Task,
+		Context:           req.Context,
+		Analysis:          analysis,
+		Constraints:       req.Constraints,
+		Samples:           s.config.Samples,
+		SampleTemperature: s.config.SampleTemperature,
+	}
+	if c := req.Constraints; c != nil {
+		if c.Samples > 0 {
+			genReq.Samples = c.Samples
+		}
+		if c.SampleTemperature > 0 {
+			genReq.SampleTemperature = c.SampleTemperature
+		}
+		genReq.MaxIterations = c.MaxIterations
+	}
+
+	// Route the generation by task complexity
+	route := s.routeTask(analysis, req.Constraints)
+	if route != nil {
+		genReq.Model = route.Model
+		genReq.MaxTokens = route.MaxTokens
+		genReq.MaxIterations = route.MaxIterations
+
+		budget := usage.Budget()
+		budget.MaxTokens = route.MaxTokensBudget
+		usage.SetBudget(budget)
+
+		s.logger.Info("routed plan by task complexity",
+			zap.String("plan_id", planID),
+			zap.String("complexity", string(route.Complexity)),
+			zap.String("route", route.Route),
+			zap.String("model", route.Model),
+			zap.Int("max_iterations", route.MaxIterations),
+		)
 	}
 
 	genResp, err := s.generator.Generate(ctx, genReq)
+
+	var budgetErr *BudgetExhaustedError
+	if errors.As(err, &budgetErr) {
+		budgetErr.Plan = s.partialPlan(planID, budgetErr, analysis, route, usage, time.Since(startTime))
+		s.logger.Warn("graph planning stopped, budget exhausted",
+			zap.String("plan_id", planID),
+			zap.Int("iterations", budgetErr.Iterations),
+			zap.Int("tokens_used", usage.Totals().TotalTokens),
+			zap.Error(err),
+		)
+		return nil, budgetErr
+	}
 	if err != nil {
+		s.logger.Warn("graph planning failed",
+			zap.String("plan_id", planID),
+			zap.Int("tokens_used", usage.Totals().TotalTokens),
+			zap.Error(err),
+		)
 		return nil, fmt.Errorf("graph generation failed: %w", err)
 	}
 
 	// Step 3: Build response
 	duration := time.Since(startTime)
-	stats := s.llmClient.GetStats()
+	stats := usage.Totals()
 
 	resp := &models.PlanResponse{
-		PlanID:         planID,
-		Graph:          genResp.Graph,
-		GraphJSON:      genResp.GraphJSON,
-		Reasoning:      genResp.Reasoning,
-		Analysis:       analysis,
-		Iterations:     genResp.Iterations,
-		ValidationLogs: genResp.ValidationLogs,
+		PlanID:           planID,
+		Graph:            genResp.Graph,
+		GraphJSON:        genResp.GraphJSON,
+		Reasoning:        genResp.Reasoning,
+		Analysis:         analysis,
+		Iterations:       genResp.Iterations,
+		ValidationLogs:   genResp.ValidationLogs,
+		Warnings:         genResp.Warnings,
+		IterationReports: genResp.Reports,
+		Alternatives:     genResp.Alternatives,
 		Metadata: &models.PlanMetadata{
-			LLMProvider:     "anthropic", // TODO: get from config
-			LLMModel:        "unknown",   // TODO: get from llm client
+			LLMProvider:     genResp.Provider,
+			LLMModel:        genResp.Model,
 			TokensUsed:      stats.TotalTokens,
+			InputTokens:     stats.InputTokens,
+			OutputTokens:    stats.OutputTokens,
+			LLMCalls:        stats.TotalCalls,
+			Continuations:   genResp.Continuations,
+			EstimatedCost:   stats.TotalCost,
+			PhaseUsage:      toPhaseUsage(usage.Phases()),
+			Samples:         genResp.Samples,
+			SampleScore:     genResp.Score,
+			Route:           route,
 			Duration:        duration,
 			ConfidenceScore: 0.0, // TODO: implement confidence scoring
 			Success:         true,
@@ -124,13 +211,139 @@ func (s *Service) Plan(ctx context.Context, req *models.PlanRequest) (*models.Pl
 		zap.String("plan_id", planID),
 		zap.Int("iterations", genResp.Iterations),
 		zap.Int("tokens_used", stats.TotalTokens),
+		zap.Float64("estimated_cost", stats.TotalCost),
 		zap.Duration("duration", duration),
 	)
 
 	return resp, nil
 }
 
-// ValidateGraph validates a graph JSON string.
-func (s *Service) ValidateGraph(graphJSON string) error {
-	return s.schemaValidator.ValidateGraph([]byte(graphJSON))
+// partialPlan assembles the response for a plan that ran out of budget,
+// carrying the best graph produced so far.
+func (s *Service) partialPlan(
+	planID string,
+	budgetErr *BudgetExhaustedError,
+	analysis *models.TaskAnalysis,
+	route *models.RouteDecision,
+	usage *llm.PlanUsage,
+	duration time.Duration,
+) *models.PlanResponse {
+	stats := usage.Totals()
+
+	provider, model := budgetErr.Provider, budgetErr.Model
+	if provider == "" {
+		provider, model = s.llmClient.Provider(), s.llmClient.Model()
+	}
+
+	resp := &models.PlanResponse{
+		PlanID:           planID,
+		GraphJSON:        budgetErr.GraphJSON,
+		Reasoning:        budgetErr.Reasoning,
+		Analysis:         analysis,
+		Iterations:       budgetErr.Iterations,
+		ValidationLogs:   budgetErr.ValidationLogs,
+		IterationReports: budgetErr.Reports,
+		Metadata: &models.PlanMetadata{
+			LLMProvider:   provider,
+			LLMModel:      model,
+			TokensUsed:    stats.TotalTokens,
+			InputTokens:   stats.InputTokens,
+			OutputTokens:  stats.OutputTokens,
+			LLMCalls:      stats.TotalCalls,
+			Continuations: budgetErr.Continuations,
+			EstimatedCost: stats.TotalCost,
+			PhaseUsage:    toPhaseUsage(usage.Phases()),
+			Route:         route,
+			Duration:      duration,
+			Success:       false,
+			ErrorMessage:  budgetErr.Error(),
+		},
+		CreatedAt: time.Now(),
+	}
+
+	if budgetErr.GraphJSON != "" {
+		if graph, err := s.generator.extractor.ParseGraph(budgetErr.GraphJSON); err == nil {
+			resp.Graph = graph
+		}
+	}
+
+	return resp
+}
+
+// toPhaseUsage converts the per-phase usage breakdown to its API model.
+func toPhaseUsage(phases []llm.PhaseUsage) []models.PhaseUsage {
+	result := make([]models.PhaseUsage, 0, len(phases))
+	for _, p := range phases {
+		result = append(result, models.PhaseUsage{
+			Phase:        p.Phase,
+			Iteration:    p.Iteration,
+			Calls:        p.Calls,
+			FailedCalls:  p.FailedCalls,
+			InputTokens:  p.InputTokens,
+			OutputTokens: p.OutputTokens,
+			TotalTokens:  p.TotalTokens,
+			Cost:         p.Cost,
+		})
+	}
+	return result
+}
+
+// Stats returns the aggregate LLM usage and cost since the service started.
+func (s *Service) Stats() *models.UsageStats {
+	stats := s.llmClient.GetStats()
+	return &models.UsageStats{
+		TotalCalls:      stats.TotalCalls,
+		SuccessfulCalls: stats.SuccessfulCalls,
+		FailedCalls:     stats.FailedCalls,
+		InputTokens:     stats.InputTokens,
+		OutputTokens:    stats.OutputTokens,
+		TotalTokens:     stats.TotalTokens,
+		TotalCost:       stats.TotalCost,
+	}
+}
+
+// LLMStatus returns the health of the configured LLM backends.
+func (s *Service) LLMStatus() []llm.BackendStatus {
+	return s.llmClient.Status()
+}
+
+// LLMAvailable reports whether at least one LLM backend accepts requests.
+func (s *Service) LLMAvailable() bool {
+	return s.llmClient.Available()
+}
+
+// LLMRetryAfter returns how long until an LLM backend accepts requests again.
+func (s *Service) LLMRetryAfter() time.Duration {
+	return s.llmClient.RetryAfter()
+}
+
+// ValidateGraph validates a graph JSON string against the schemas and, if
+// enabled, lints it. Lint errors make the graph invalid; lint warnings are
+// returned alongside.
+func (s *Service) ValidateGraph(graphJSON string) *models.ValidationResult {
+	if err := s.schemaValidator.ValidateGraph([]byte(graphJSON)); err != nil {
+		return &models.ValidationResult{
+			Valid:  false,
+			Errors: []string{err.Error()},
+		}
+	}
+
+	if !s.config.EnableLint {
+		return &models.ValidationResult{Valid: true}
+	}
+
+	findings, err := lint.LintJSON([]byte(graphJSON))
+	if err != nil {
+		return &models.ValidationResult{
+			Valid:  false,
+			Errors: []string{err.Error()},
+		}
+	}
+
+	errs := lint.Errors(findings)
+	return &models.ValidationResult{
+		Valid:    len(errs) == 0,
+		Errors:   lint.Strings(errs),
+		Warnings: lint.Strings(lint.Warnings(findings)),
+	}
 }
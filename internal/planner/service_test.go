@@ -0,0 +1,119 @@
+package planner
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago-libs/pkg/schema"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/pkg/models"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "re-record the cassettes and golden files in testdata")
+
+// newReplayService creates a Service whose LLM calls are replayed from the
+// cassette at path, or recorded to it from testdata/fake-script.yaml with -update.
+func newReplayService(t *testing.T, path string) *Service {
+	t.Helper()
+	logger := zap.NewNop()
+
+	mode := llm.CassetteReplay
+	if *update {
+		mode = llm.CassetteRecord
+	}
+	cassette, err := llm.OpenCassette(config.CassetteConfig{Mode: mode, Path: path}, logger)
+	if err != nil {
+		t.Fatalf("OpenCassette() error = %v", err)
+	}
+
+	var inner ports.LLMClient
+	if *update {
+		inner, err = llm.NewFakeClient(llm.FakeConfig{ScriptPath: "testdata/fake-script.yaml"}, logger)
+		if err != nil {
+			t.Fatalf("NewFakeClient() error = %v", err)
+		}
+	}
+
+	llmCfg := &config.LLMConfig{
+		Provider:         "fake",
+		Model:            "fake-planner",
+		MaxTokens:        4096,
+		RetryConfig:      config.RetryConfig{MaxAttempts: 1},
+		StructuredOutput: true,
+	}
+	client := llm.NewClient(cassette.Wrap(inner), llmCfg, logger)
+
+	validator, err := schema.NewValidator()
+	if err != nil {
+		t.Fatalf("schema.NewValidator() error = %v", err)
+	}
+
+	return NewService(client, validator, &config.PlanningConfig{
+		MaxIterations:     3,
+		MaxNodes:          50,
+		EnableValidation:  true,
+		EnableAnalysis:    true,
+		EnableLint:        true,
+		RepairHistory:     2,
+		MaxContinuations:  2,
+		Samples:           1,
+		SampleTemperature: 0.7,
+	}, logger)
+}
+
+func TestServicePlanReplay(t *testing.T) {
+	service := newReplayService(t, "testdata/cassettes/plan.json")
+
+	resp, err := service.Plan(context.Background(), &models.PlanRequest{
+		Task: "Send a welcome email to the new user",
+		Context: map[string]any{
+			"user_email": "newuser@example.com",
+		},
+		Constraints: &models.Constraints{MaxNodes: 5},
+	})
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	if resp.Graph == nil {
+		t.Fatal("Plan() returned no graph")
+	}
+	if resp.Graph.EntryNode != "send_welcome_email" {
+		t.Errorf("entry node = %q, want send_welcome_email", resp.Graph.EntryNode)
+	}
+	if node := resp.Graph.Node("send_welcome_email"); node == nil || node.ExecutorType != "tool" {
+		t.Errorf("node send_welcome_email = %+v, want a tool executor", node)
+	}
+	if len(resp.Graph.Edges) != 0 {
+		t.Errorf("edges = %d, want the dangling edge repaired away", len(resp.Graph.Edges))
+	}
+
+	// The first graph fails lint and is repaired in the second iteration
+	if resp.Iterations != 2 {
+		t.Errorf("Iterations = %d, want 2 (logs: %v)", resp.Iterations, resp.ValidationLogs)
+	}
+	if resp.Analysis == nil || resp.Analysis.Complexity != "simple" {
+		t.Errorf("Analysis = %+v, want a simple task", resp.Analysis)
+	}
+
+	meta := resp.Metadata
+	if meta == nil {
+		t.Fatal("Plan() returned no metadata")
+	}
+	if !meta.Success {
+		t.Errorf("Success = false: %s", meta.ErrorMessage)
+	}
+	if meta.LLMProvider != "fake" || meta.LLMModel != "fake-planner" {
+		t.Errorf("LLM = %s/%s, want fake/fake-planner", meta.LLMProvider, meta.LLMModel)
+	}
+	if meta.LLMCalls != 3 {
+		t.Errorf("LLMCalls = %d, want 3 (analysis, generation, repair)", meta.LLMCalls)
+	}
+	if meta.TokensUsed == 0 {
+		t.Error("TokensUsed = 0, want the recorded usage")
+	}
+}
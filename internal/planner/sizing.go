@@ -0,0 +1,146 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"go.uber.org/zap"
+)
+
+// contextValueChars is the length at which context values are cut when the
+// context has to be summarized.
+const contextValueChars = 200
+
+// promptSection is an optional part of a prompt template that may be
+// shortened or dropped to fit the prompt in the context window.
+type promptSection struct {
+	name        string
+	placeholder string
+	content     string
+
+	// shorten returns the content cut down to about maxTokens tokens, or ""
+	// if it cannot be shortened that far. Sections without it are dropped whole.
+	shorten func(maxTokens int) string
+}
+
+// fitPrompt fills the sections into template, which must already hold the
+// required parts of the prompt. If the result exceeds maxTokens, sections
+// are shortened or dropped in the order given until it fits; if it never
+// does, ErrContextWindowExceeded is returned. A maxTokens of zero or less
+// means there is no limit.
+func (p *Prompter) fitPrompt(template string, sections []*promptSection, maxTokens int) (string, error) {
+	render := func() string {
+		prompt := template
+		for _, s := range sections {
+			prompt = strings.ReplaceAll(prompt, s.placeholder, s.content)
+		}
+		return prompt
+	}
+
+	prompt := render()
+	if maxTokens <= 0 {
+		return prompt, nil
+	}
+
+	var trimmed []string
+	for _, s := range sections {
+		excess := llm.EstimateTokens(prompt) - maxTokens
+		if excess <= 0 {
+			break
+		}
+
+		size := llm.EstimateTokens(s.content)
+		if size == 0 {
+			continue
+		}
+
+		if keep := size - excess; s.shorten != nil && keep > 0 {
+			s.content = s.shorten(keep)
+		} else {
+			s.content = ""
+		}
+		trimmed = append(trimmed, s.name)
+		prompt = render()
+	}
+
+	if tokens := llm.EstimateTokens(prompt); tokens > maxTokens {
+		return "", fmt.Errorf("%w: prompt needs about %d tokens but only %d are available",
+			llm.ErrContextWindowExceeded, tokens, maxTokens)
+	}
+
+	if len(trimmed) > 0 {
+		p.logger.Info("prompt trimmed to fit the context window",
+			zap.Strings("sections", trimmed),
+			zap.Int("max_tokens", maxTokens),
+		)
+	}
+
+	return prompt, nil
+}
+
+// formatContext renders the task context for a prompt.
+func formatContext(taskContext map[string]any) string {
+	if len(taskContext) == 0 {
+		return ""
+	}
+
+	data, err := json.MarshalIndent(taskContext, "", "  ")
+	if err != nil {
+		return summarizeContextKeys(taskContext)
+	}
+	return fmt.Sprintf("\nContext:\n%s\n", data)
+}
+
+// summarizeContext shortens the task context to about maxTokens tokens:
+// first by cutting long values, then by listing only its keys.
+func summarizeContext(taskContext map[string]any, maxTokens int) string {
+	summary := make(map[string]string, len(taskContext))
+	for key, value := range taskContext {
+		data, err := json.Marshal(value)
+		if err != nil {
+			data = []byte(fmt.Sprint(value))
+		}
+		text := string(data)
+		if runes := []rune(text); len(runes) > contextValueChars {
+			text = string(runes[:contextValueChars]) + "... (truncated)"
+		}
+		summary[key] = text
+	}
+
+	if data, err := json.MarshalIndent(summary, "", "  "); err == nil {
+		text := fmt.Sprintf("\nContext (values truncated):\n%s\n", data)
+		if llm.EstimateTokens(text) <= maxTokens {
+			return text
+		}
+	}
+
+	if text := summarizeContextKeys(taskContext); llm.EstimateTokens(text) <= maxTokens {
+		return text
+	}
+	return ""
+}
+
+// summarizeContextKeys renders only the keys of the task context.
+func summarizeContextKeys(taskContext map[string]any) string {
+	keys := make([]string, 0, len(taskContext))
+	for key := range taskContext {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return fmt.Sprintf("\nContext keys (values omitted): %s\n", strings.Join(keys, ", "))
+}
+
+// truncateGraph shortens a previous graph to about maxTokens tokens.
+func truncateGraph(graph string, maxTokens int) string {
+	const marker = "\n... (truncated)"
+
+	keep := maxTokens - llm.EstimateTokens(marker)
+	if keep <= 0 {
+		return ""
+	}
+	return llm.TruncateToTokens(graph, keep) + marker
+}
@@ -0,0 +1,113 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/aescanero/dago-node-planner/internal/config"
+)
+
+// Strategy adjusts the next iteration based on the failed iterations before
+// it. Strategies are stateless; history holds every failed iteration so far.
+type Strategy interface {
+	Next(history []*Iteration, next *Iteration)
+}
+
+// NewStrategies creates the iteration strategies enabled in cfg, in the
+// order they are applied.
+func NewStrategies(cfg config.StrategiesConfig) []Strategy {
+	var strategies []Strategy
+
+	if cfg.Restart.After > 0 {
+		strategies = append(strategies, &restartStrategy{after: cfg.Restart.After})
+	}
+	if cfg.Escalate.After > 0 && cfg.Escalate.Model != "" {
+		strategies = append(strategies, &escalateStrategy{
+			after: cfg.Escalate.After,
+			model: cfg.Escalate.Model,
+		})
+	}
+	if cfg.Temperature.Repeats > 0 && cfg.Temperature.Step > 0 {
+		strategies = append(strategies, &temperatureStrategy{
+			repeats: cfg.Temperature.Repeats,
+			step:    cfg.Temperature.Step,
+			max:     cfg.Temperature.Max,
+		})
+	}
+
+	return strategies
+}
+
+// escalateStrategy switches to a stronger model once the given number of
+// repairs have failed, and keeps it for the remaining iterations.
+type escalateStrategy struct {
+	after int
+	model string
+}
+
+func (s *escalateStrategy) Next(history []*Iteration, next *Iteration) {
+	// The first failure is the initial generation, the rest are repairs
+	if len(history)-1 < s.after {
+		return
+	}
+
+	next.Model = s.model
+	next.Actions = append(next.Actions, "escalate:"+s.model)
+}
+
+// restartStrategy starts over from the planning prompt once the given number
+// of consecutive repairs have failed, instead of repairing the same graph.
+type restartStrategy struct {
+	after int
+}
+
+func (s *restartStrategy) Next(history []*Iteration, next *Iteration) {
+	repairs := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Attempt == 1 || history[i].Restart {
+			break
+		}
+		repairs++
+	}
+	if repairs < s.after {
+		return
+	}
+
+	next.Restart = true
+	next.Actions = append(next.Actions, "restart")
+}
+
+// temperatureStrategy raises the temperature by step each time the latest
+// iterations failed with the same error the given number of times in a row.
+type temperatureStrategy struct {
+	repeats int
+	step    float64
+	max     float64
+}
+
+func (s *temperatureStrategy) Next(history []*Iteration, next *Iteration) {
+	last := history[len(history)-1]
+	next.TemperatureBoost = last.TemperatureBoost
+	next.MaxTemperature = s.max
+
+	identical := 1
+	for i := len(history) - 2; i >= 0; i-- {
+		if errorText(history[i].Err) != errorText(last.Err) {
+			break
+		}
+		identical++
+	}
+	if identical < s.repeats {
+		return
+	}
+
+	next.TemperatureBoost += s.step
+	next.Actions = append(next.Actions, fmt.Sprintf("temperature:+%.2f", next.TemperatureBoost))
+}
+
+// errorText returns the message of err, or "" for nil.
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
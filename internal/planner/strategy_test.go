@@ -0,0 +1,218 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/schema"
+	"github.com/aescanero/dago-node-planner/internal/config"
+	"github.com/aescanero/dago-node-planner/internal/llm"
+	"github.com/aescanero/dago-node-planner/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestIteratorStrategies(t *testing.T) {
+	errSame := errors.New("graph lint failed: edge to unknown node")
+
+	tests := []struct {
+		name       string
+		strategies config.StrategiesConfig
+		errs       []error // per failed iteration; the last iteration succeeds
+		want       []Iteration
+	}{
+		{
+			name:       "escalate after failed repairs",
+			strategies: config.StrategiesConfig{Escalate: config.EscalateStrategyConfig{After: 1, Model: "strong-model"}},
+			errs:       []error{errSame, errSame, errSame},
+			want: []Iteration{
+				{Attempt: 1},
+				{Attempt: 2},
+				{Attempt: 3, Model: "strong-model", Actions: []string{"escalate:strong-model"}},
+				{Attempt: 4, Model: "strong-model", Actions: []string{"escalate:strong-model"}},
+			},
+		},
+		{
+			name:       "restart after consecutive failed repairs",
+			strategies: config.StrategiesConfig{Restart: config.RestartStrategyConfig{After: 2}},
+			errs:       []error{errSame, errSame, errSame, errSame, errSame},
+			want: []Iteration{
+				{Attempt: 1},
+				{Attempt: 2},
+				{Attempt: 3},
+				{Attempt: 4, Restart: true, Actions: []string{"restart"}},
+				{Attempt: 5},
+				{Attempt: 6},
+			},
+		},
+		{
+			name: "temperature rises on repeated errors",
+			strategies: config.StrategiesConfig{
+				Temperature: config.TemperatureStrategyConfig{Repeats: 2, Step: 0.25, Max: 1.0},
+			},
+			errs: []error{errSame, errSame, errSame, errors.New("another error")},
+			want: []Iteration{
+				{Attempt: 1},
+				{Attempt: 2, MaxTemperature: 1.0},
+				{Attempt: 3, MaxTemperature: 1.0, TemperatureBoost: 0.25, Actions: []string{"temperature:+0.25"}},
+				{Attempt: 4, MaxTemperature: 1.0, TemperatureBoost: 0.5, Actions: []string{"temperature:+0.50"}},
+				{Attempt: 5, MaxTemperature: 1.0, TemperatureBoost: 0.5},
+			},
+		},
+		{
+			name: "strategies combine in order",
+			strategies: config.StrategiesConfig{
+				Restart:     config.RestartStrategyConfig{After: 1},
+				Escalate:    config.EscalateStrategyConfig{After: 1, Model: "strong-model"},
+				Temperature: config.TemperatureStrategyConfig{Repeats: 2, Step: 0.1, Max: 1.0},
+			},
+			errs: []error{errSame, errSame},
+			want: []Iteration{
+				{Attempt: 1},
+				{Attempt: 2, MaxTemperature: 1.0},
+				{
+					Attempt: 3, Model: "strong-model", Restart: true, MaxTemperature: 1.0, TemperatureBoost: 0.1,
+					Actions: []string{"restart", "escalate:strong-model", "temperature:+0.10"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iterator := NewIterator(len(tt.errs)+1, NewStrategies(tt.strategies), zap.NewNop())
+
+			var got []Iteration
+			err := iterator.Iterate(context.Background(), func(ctx context.Context, iter *Iteration) error {
+				got = append(got, *iter)
+				if iter.Attempt > len(tt.errs) {
+					return nil
+				}
+				return tt.errs[iter.Attempt-1]
+			})
+			if err != nil {
+				t.Fatalf("Iterate() error = %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Iterate() ran %d iterations, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Attempt != want.Attempt || g.Model != want.Model || g.Restart != want.Restart ||
+					g.TemperatureBoost != want.TemperatureBoost || g.MaxTemperature != want.MaxTemperature ||
+					!slices.Equal(g.Actions, want.Actions) {
+					t.Errorf("iteration %d = %+v, want %+v", i+1, g, want)
+				}
+			}
+		})
+	}
+}
+
+// newFailingService creates a Service whose provider answers with prose the
+// given number of times before returning a valid graph.
+func newFailingService(t *testing.T, failures int, strategies config.StrategiesConfig) *Service {
+	t.Helper()
+	logger := zap.NewNop()
+
+	script := fmt.Sprintf("model: fake-planner\nresponses:\n  - times: %d\n    fault: prose\ndefault:\n  content: %q\n",
+		failures, `{"reasoning": "One tool call sends the email", "graph": `+testGraph+`}`)
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	fake, err := llm.NewFakeClient(llm.FakeConfig{ScriptPath: path}, logger)
+	if err != nil {
+		t.Fatalf("NewFakeClient() error = %v", err)
+	}
+
+	client := llm.NewClient(fake, &config.LLMConfig{
+		Provider:    "fake",
+		Model:       "fake-planner",
+		MaxTokens:   4096,
+		Temperature: 0.2,
+		RetryConfig: config.RetryConfig{MaxAttempts: 1},
+	}, logger)
+
+	validator, err := schema.NewValidator()
+	if err != nil {
+		t.Fatalf("schema.NewValidator() error = %v", err)
+	}
+
+	return NewService(client, validator, &config.PlanningConfig{
+		MaxIterations:    failures + 1,
+		MaxNodes:         50,
+		EnableValidation: true,
+		Samples:          1,
+		Strategies:       strategies,
+	}, logger)
+}
+
+func TestServiceIterationReports(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategies config.StrategiesConfig
+		want       []models.IterationReport
+	}{
+		{
+			name:       "escalate",
+			strategies: config.StrategiesConfig{Escalate: config.EscalateStrategyConfig{After: 1, Model: "strong-model"}},
+			want: []models.IterationReport{
+				{Iteration: 1, Phase: PhaseGeneration, Model: "fake-planner", Temperature: 0.2},
+				{Iteration: 2, Phase: PhaseRepair, Model: "fake-planner", Temperature: 0.2},
+				{Iteration: 3, Phase: PhaseRepair, Model: "strong-model", Temperature: 0.2, Actions: []string{"escalate:strong-model"}},
+			},
+		},
+		{
+			name:       "restart",
+			strategies: config.StrategiesConfig{Restart: config.RestartStrategyConfig{After: 1}},
+			want: []models.IterationReport{
+				{Iteration: 1, Phase: PhaseGeneration, Model: "fake-planner", Temperature: 0.2},
+				{Iteration: 2, Phase: PhaseRepair, Model: "fake-planner", Temperature: 0.2},
+				{Iteration: 3, Phase: PhaseGeneration, Model: "fake-planner", Temperature: 0.2, Restarted: true, Actions: []string{"restart"}},
+			},
+		},
+		{
+			name: "temperature",
+			strategies: config.StrategiesConfig{
+				Temperature: config.TemperatureStrategyConfig{Repeats: 1, Step: 0.5, Max: 0.9},
+			},
+			want: []models.IterationReport{
+				{Iteration: 1, Phase: PhaseGeneration, Model: "fake-planner", Temperature: 0.2},
+				{Iteration: 2, Phase: PhaseRepair, Model: "fake-planner", Temperature: 0.7, Actions: []string{"temperature:+0.50"}},
+				{Iteration: 3, Phase: PhaseRepair, Model: "fake-planner", Temperature: 0.9, Actions: []string{"temperature:+1.00"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newFailingService(t, len(tt.want)-1, tt.strategies)
+
+			resp, err := service.Plan(context.Background(), &models.PlanRequest{Task: "Send a welcome email to the new user"})
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+
+			reports := resp.IterationReports
+			if len(reports) != len(tt.want) {
+				t.Fatalf("IterationReports = %+v, want %d iterations", reports, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := reports[i]
+				if got.Iteration != want.Iteration || got.Phase != want.Phase || got.Model != want.Model ||
+					got.Temperature != want.Temperature || got.Restarted != want.Restarted || !slices.Equal(got.Actions, want.Actions) {
+					t.Errorf("iteration %d = %+v, want %+v", i+1, got, want)
+				}
+				if failed := i < len(tt.want)-1; failed != (got.Error != "") {
+					t.Errorf("iteration %d error = %q, want failed = %v", i+1, got.Error, failed)
+				}
+			}
+		})
+	}
+}
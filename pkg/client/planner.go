@@ -13,6 +13,21 @@ import (
 	"github.com/aescanero/dago-node-planner/pkg/models"
 )
 
+// BudgetExhaustedError is returned by Plan when the plan ran out of its
+// token or cost budget. Plan holds the best graph produced so far.
+type BudgetExhaustedError struct {
+	// Details describes why the budget was exhausted
+	Details string
+
+	// Plan is the partial plan, with Metadata.Success set to false
+	Plan *models.PlanResponse
+}
+
+// Error implements the error interface.
+func (e *BudgetExhaustedError) Error() string {
+	return fmt.Sprintf("planning budget exhausted: %s", e.Details)
+}
+
 // Client is a client for the dago-node-planner service.
 type Client struct {
 	baseURL    string
@@ -38,6 +53,7 @@ func NewClientWithHTTPClient(baseURL string, httpClient *http.Client) *Client {
 }
 
 // Plan generates a graph from a task description.
+// If the plan exceeds its budget constraints, the error is a *BudgetExhaustedError.
 func (c *Client) Plan(ctx context.Context, req *models.PlanRequest) (*models.PlanResponse, error) {
 	url := fmt.Sprintf("%s/api/v1/plan", c.baseURL)
 
@@ -59,6 +75,17 @@ func (c *Client) Plan(ctx context.Context, req *models.PlanRequest) (*models.Pla
 	}
 	defer func() { _ = httpResp.Body.Close() }()
 
+	if httpResp.StatusCode == http.StatusUnprocessableEntity {
+		var budgetResp struct {
+			Details string               `json:"details"`
+			Plan    *models.PlanResponse `json:"plan"`
+		}
+		if err := json.NewDecoder(httpResp.Body).Decode(&budgetResp); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil, &BudgetExhaustedError{Details: budgetResp.Details, Plan: budgetResp.Plan}
+	}
+
 	if httpResp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(httpResp.Body)
 		return nil, fmt.Errorf("request failed with status %d: %s", httpResp.StatusCode, string(bodyBytes))
@@ -111,6 +138,44 @@ func (c *Client) Validate(ctx context.Context, graphJSON string) (*models.Valida
 	return &resp, nil
 }
 
+// ValidateGraph validates a typed graph.
+func (c *Client) ValidateGraph(ctx context.Context, graph *models.Graph) (*models.ValidationResult, error) {
+	graphJSON, err := json.Marshal(graph)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal graph: %w", err)
+	}
+
+	return c.Validate(ctx, string(graphJSON))
+}
+
+// Stats returns aggregate LLM usage and cost since the service started.
+func (c *Client) Stats(ctx context.Context) (*models.UsageStats, error) {
+	url := fmt.Sprintf("%s/api/v1/stats", c.baseURL)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	if httpResp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(httpResp.Body)
+		return nil, fmt.Errorf("request failed with status %d: %s", httpResp.StatusCode, string(bodyBytes))
+	}
+
+	var resp models.UsageStats
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &resp, nil
+}
+
 // Health checks the health of the service.
 func (c *Client) Health(ctx context.Context) error {
 	url := fmt.Sprintf("%s/health", c.baseURL)
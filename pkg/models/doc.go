@@ -7,8 +7,12 @@
 //   - Task: Represents a natural language task to be converted into a graph
 //   - PlanRequest: Request to generate a graph from a task
 //   - PlanResponse: Response containing the generated graph and metadata
+//   - Graph: Typed execution graph (Node, ExecutorConfig, RouterConfig,
+//     Route, Edge, ToolCall) that keeps unknown fields on round trips
 //   - TaskAnalysis: Results of task analysis before planning
 //   - PlanMetadata: Metadata about the planning process
+//   - PhaseUsage: Token usage and cost of a single planning phase
+//   - UsageStats: Aggregate LLM usage since the service started
 //   - ValidationResult: Result of graph schema validation
 //   - IterationLog: Log of a single planning iteration
 //
@@ -0,0 +1,578 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Node types.
+const (
+	NodeTypeExecutor = "executor"
+	NodeTypeRouter   = "router"
+)
+
+// Graph is an execution graph as defined by the dago-libs graph schema.
+// Fields the model does not know are kept in Extra and written back when
+// the graph is marshalled.
+type Graph struct {
+	// ID is the unique identifier of the graph
+	ID string `json:"id"`
+
+	// Name is a human-readable name for the graph
+	Name string `json:"name,omitempty"`
+
+	// Description describes what the graph does
+	Description string `json:"description,omitempty"`
+
+	// Version is the schema version
+	Version string `json:"version,omitempty"`
+
+	// Nodes maps node IDs to nodes
+	Nodes map[string]*Node `json:"nodes"`
+
+	// Edges connects the nodes
+	Edges []*Edge `json:"edges,omitempty"`
+
+	// EntryNode is the ID of the node execution starts at
+	EntryNode string `json:"entry_node"`
+
+	// Metadata holds graph-level metadata
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Extra holds the fields not covered by the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Node is an executor or router node. Executor nodes set ExecutorType and
+// Config; router nodes set the RouterConfig fields, which the schema places
+// on the node itself.
+type Node struct {
+	// ID is the unique identifier of the node
+	ID string `json:"id"`
+
+	// Type is the node type ("executor" or "router")
+	Type string `json:"type"`
+
+	// Name is a human-readable name for the node
+	Name string `json:"name,omitempty"`
+
+	// Description describes what the node does
+	Description string `json:"description,omitempty"`
+
+	// ExecutorType is the executor of an executor node (llm, tool, python, bash, http, custom)
+	ExecutorType string `json:"executor_type,omitempty"`
+
+	// Config is the executor-specific configuration of an executor node
+	Config *ExecutorConfig `json:"config,omitempty"`
+
+	// InputMapping maps state keys to executor inputs
+	InputMapping map[string]string `json:"input_mapping,omitempty"`
+
+	// OutputMapping maps executor outputs to state keys
+	OutputMapping map[string]string `json:"output_mapping,omitempty"`
+
+	RouterConfig
+
+	// Metadata holds node-level metadata
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Extra holds the fields not covered by the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// ExecutorConfig is the configuration of an executor node. Only the fields
+// of the node's executor type are set.
+type ExecutorConfig struct {
+	// Model is the LLM model of llm executors
+	Model string `json:"model,omitempty"`
+
+	// Prompt is the prompt of llm executors
+	Prompt string `json:"prompt,omitempty"`
+
+	// SystemPrompt is the system message of llm executors
+	SystemPrompt string `json:"system_prompt,omitempty"`
+
+	// Temperature is the sampling temperature of llm executors
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// MaxTokens limits the output of llm executors
+	MaxTokens int `json:"max_tokens,omitempty"`
+
+	// Tools are the tools llm executors may call
+	Tools []map[string]any `json:"tools,omitempty"`
+
+	ToolCall
+
+	// Code is the Python code of python executors
+	Code string `json:"code,omitempty"`
+
+	// ScriptPath is the Python script of python executors
+	ScriptPath string `json:"script_path,omitempty"`
+
+	// Requirements are the Python packages of python executors
+	Requirements []string `json:"requirements,omitempty"`
+
+	// Command is the command of bash executors
+	Command string `json:"command,omitempty"`
+
+	// WorkingDir is the working directory of bash executors
+	WorkingDir string `json:"working_dir,omitempty"`
+
+	// Environment holds the environment variables of bash executors
+	Environment map[string]any `json:"environment,omitempty"`
+
+	// URL is the endpoint of http executors
+	URL string `json:"url,omitempty"`
+
+	// Method is the HTTP method of http executors
+	Method string `json:"method,omitempty"`
+
+	// Headers holds the HTTP headers of http executors
+	Headers map[string]any `json:"headers,omitempty"`
+
+	// Body is the request body of http executors
+	Body any `json:"body,omitempty"`
+
+	// Timeout is the execution timeout in seconds
+	Timeout int `json:"timeout,omitempty"`
+
+	// Extra holds the fields not covered by the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// ToolCall is the tool invocation of a tool executor.
+type ToolCall struct {
+	// ToolName is the name of the tool to execute
+	ToolName string `json:"tool_name,omitempty"`
+
+	// Parameters holds static parameters for the tool
+	Parameters map[string]any `json:"parameters,omitempty"`
+}
+
+// RouterConfig holds the routing rules of a router node.
+type RouterConfig struct {
+	// Routes are the conditional routing rules
+	Routes []*Route `json:"routes,omitempty"`
+
+	// DefaultRoute is the node taken when no condition matches
+	DefaultRoute string `json:"default_route,omitempty"`
+
+	// ConditionType is how conditions are evaluated (jsonpath, simple, custom)
+	ConditionType string `json:"condition_type,omitempty"`
+}
+
+// Route is a conditional routing rule of a router node.
+type Route struct {
+	// Condition is the expression deciding the route
+	Condition string `json:"condition,omitempty"`
+
+	// Target is the ID of the node routed to
+	Target string `json:"target"`
+
+	// Description describes the route
+	Description string `json:"description,omitempty"`
+
+	// Priority orders the routes (higher values are evaluated first)
+	Priority int `json:"priority,omitempty"`
+
+	// Extra holds the fields not covered by the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Edge connects two nodes.
+type Edge struct {
+	// ID is the optional identifier of the edge
+	ID string `json:"id,omitempty"`
+
+	// From is the ID of the source node
+	From string `json:"from"`
+
+	// To is the ID of the target node
+	To string `json:"to"`
+
+	// Condition is an optional condition for traversing the edge
+	Condition string `json:"condition,omitempty"`
+
+	// Label is a human-readable label
+	Label string `json:"label,omitempty"`
+
+	// Metadata holds edge-level metadata
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Extra holds the fields not covered by the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// ParseGraph parses graph JSON.
+func ParseGraph(data []byte) (*Graph, error) {
+	var graph Graph
+	if err := json.Unmarshal(data, &graph); err != nil {
+		return nil, fmt.Errorf("failed to parse graph JSON: %w", err)
+	}
+	return &graph, nil
+}
+
+// Node returns the node with the given ID, or nil.
+func (g *Graph) Node(id string) *Node {
+	return g.Nodes[id]
+}
+
+// NodeIDs returns the IDs of the nodes in order.
+func (g *Graph) NodeIDs() []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Successors returns the IDs the node leads to through edges, routes and
+// its default route, without duplicates.
+func (g *Graph) Successors(id string) []string {
+	var targets []string
+	for _, edge := range g.Edges {
+		if edge != nil && edge.From == id {
+			targets = append(targets, edge.To)
+		}
+	}
+	if node := g.Nodes[id]; node != nil {
+		targets = append(targets, node.Targets()...)
+	}
+	return unique(targets)
+}
+
+// Predecessors returns the IDs of the nodes leading to the node through
+// edges, routes or default routes, without duplicates.
+func (g *Graph) Predecessors(id string) []string {
+	var sources []string
+	for _, edge := range g.Edges {
+		if edge != nil && edge.To == id {
+			sources = append(sources, edge.From)
+		}
+	}
+	for _, from := range g.NodeIDs() {
+		for _, target := range g.Nodes[from].Targets() {
+			if target == id {
+				sources = append(sources, from)
+			}
+		}
+	}
+	return unique(sources)
+}
+
+// Reachable returns the IDs reachable from the entry node, including it.
+func (g *Graph) Reachable() map[string]bool {
+	reached := map[string]bool{g.EntryNode: true}
+	queue := []string{g.EntryNode}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range g.Successors(id) {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
+
+// IsExecutor reports whether the node is an executor node.
+func (n *Node) IsExecutor() bool {
+	return n.Type == NodeTypeExecutor
+}
+
+// IsRouter reports whether the node is a router node.
+func (n *Node) IsRouter() bool {
+	return n.Type == NodeTypeRouter
+}
+
+// Targets returns the route targets and default route of the node.
+func (n *Node) Targets() []string {
+	if n == nil {
+		return nil
+	}
+
+	var targets []string
+	for _, route := range n.Routes {
+		if route != nil {
+			targets = append(targets, route.Target)
+		}
+	}
+	if n.DefaultRoute != "" {
+		targets = append(targets, n.DefaultRoute)
+	}
+	return targets
+}
+
+// ToolName returns the tool called by the node, or "".
+func (n *Node) ToolName() string {
+	if n.Config == nil {
+		return ""
+	}
+	return n.Config.ToolName
+}
+
+// The JSON methods keep unknown fields in Extra. Each decodes into a copy
+// of its type without methods to avoid recursing.
+//
+// LLMs fill graphs loosely (e.g. "timeout": "30s" or "tools": ["web_search"]
+// in executor configs, "priority": "high" in routes), and the schema leaves
+// much of that open. Fields whose value does not fit the model are kept in
+// Extra as they are instead of failing, and written back in place of the
+// empty typed value.
+
+// UnmarshalJSON implements json.Unmarshaler. Nodes given as an array are
+// keyed by their IDs.
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	type graphFields Graph
+	var fields struct {
+		graphFields
+		Nodes json.RawMessage `json:"nodes"`
+	}
+	extra, err := unmarshalExtra(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	*g = Graph(fields.graphFields)
+	g.Extra = extra
+
+	nodes := strings.TrimSpace(string(fields.Nodes))
+	switch {
+	case strings.HasPrefix(nodes, "["):
+		var list []*Node
+		if err := json.Unmarshal(fields.Nodes, &list); err != nil {
+			g.keepNodes(fields.Nodes)
+			break
+		}
+		g.Nodes = make(map[string]*Node, len(list))
+		for _, node := range list {
+			if node != nil {
+				g.Nodes[node.ID] = node
+			}
+		}
+	case nodes != "" && nodes != "null":
+		if err := json.Unmarshal(fields.Nodes, &g.Nodes); err != nil {
+			g.Nodes = nil
+			g.keepNodes(fields.Nodes)
+		}
+	}
+
+	return nil
+}
+
+// keepNodes keeps nodes that do not fit the model in Extra.
+func (g *Graph) keepNodes(nodes json.RawMessage) {
+	if g.Extra == nil {
+		g.Extra = make(map[string]json.RawMessage)
+	}
+	g.Extra["nodes"] = nodes
+}
+
+// MarshalJSON implements json.Marshaler.
+func (g Graph) MarshalJSON() ([]byte, error) {
+	type graphFields Graph
+	return marshalExtra(graphFields(g), g.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	type nodeFields Node
+	var fields nodeFields
+	extra, err := unmarshalExtra(data, &fields)
+	if err != nil {
+		return err
+	}
+	*n = Node(fields)
+	n.Extra = extra
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n Node) MarshalJSON() ([]byte, error) {
+	type nodeFields Node
+	return marshalExtra(nodeFields(n), n.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *ExecutorConfig) UnmarshalJSON(data []byte) error {
+	type configFields ExecutorConfig
+	var fields configFields
+	extra, err := unmarshalExtra(data, &fields)
+	if err != nil {
+		return err
+	}
+	*c = ExecutorConfig(fields)
+	c.Extra = extra
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c ExecutorConfig) MarshalJSON() ([]byte, error) {
+	type configFields ExecutorConfig
+	return marshalExtra(configFields(c), c.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Route) UnmarshalJSON(data []byte) error {
+	type routeFields Route
+	var fields routeFields
+	extra, err := unmarshalExtra(data, &fields)
+	if err != nil {
+		return err
+	}
+	*r = Route(fields)
+	r.Extra = extra
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r Route) MarshalJSON() ([]byte, error) {
+	type routeFields Route
+	return marshalExtra(routeFields(r), r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Edge) UnmarshalJSON(data []byte) error {
+	type edgeFields Edge
+	var fields edgeFields
+	extra, err := unmarshalExtra(data, &fields)
+	if err != nil {
+		return err
+	}
+	*e = Edge(fields)
+	e.Extra = extra
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e Edge) MarshalJSON() ([]byte, error) {
+	type edgeFields Edge
+	return marshalExtra(edgeFields(e), e.Extra)
+}
+
+// unmarshalExtra decodes the JSON object data into the struct v points to
+// and returns the fields of data that are not fields of the struct, along
+// with the fields whose values do not fit their types.
+func unmarshalExtra(data []byte, v any) (map[string]json.RawMessage, error) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	target := reflect.ValueOf(v).Elem()
+	fields := jsonFields(target.Type())
+
+	var extra map[string]json.RawMessage
+	keep := func(name string, value json.RawMessage) {
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[name] = value
+	}
+
+	for name, value := range values {
+		field, ok := fields[name]
+		if !ok {
+			keep(name, value)
+			continue
+		}
+
+		decoded := reflect.New(field.typ)
+		if err := json.Unmarshal(value, decoded.Interface()); err != nil {
+			keep(name, value)
+			continue
+		}
+		target.FieldByIndex(field.index).Set(decoded.Elem())
+	}
+
+	return extra, nil
+}
+
+// marshalExtra encodes v with the extra fields added. An extra field
+// replaces a field of v only if v leaves it empty, as a field that did not
+// fit its type was decoded.
+func marshalExtra(v any, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if current, ok := fields[name]; !ok || isEmptyJSON(current) {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// isEmptyJSON reports whether value is an empty string or null.
+func isEmptyJSON(value json.RawMessage) bool {
+	v := string(value)
+	return v == `""` || v == "null"
+}
+
+// jsonField is a field of a struct as seen by encoding/json.
+type jsonField struct {
+	index []int
+	typ   reflect.Type
+}
+
+// jsonFields returns the fields of struct type t by JSON name, including
+// those promoted from embedded structs. Fields of t shadow promoted ones.
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+	var embedded []reflect.StructField
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, field)
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = jsonField{index: field.Index, typ: field.Type}
+	}
+
+	for _, field := range embedded {
+		for name, promoted := range jsonFields(field.Type) {
+			if _, ok := fields[name]; ok {
+				continue
+			}
+			promoted.index = append([]int{field.Index[0]}, promoted.index...)
+			fields[name] = promoted
+		}
+	}
+
+	return fields
+}
+
+// unique returns ids without duplicates, keeping their order.
+func unique(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	var result []string
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
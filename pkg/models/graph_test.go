@@ -0,0 +1,251 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// assertSameJSON fails unless got and want encode the same JSON value.
+func assertSameJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("expected value is not valid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("JSON = %s\nwant %s", got, want)
+	}
+}
+
+func TestGraphRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph string
+	}{
+		{
+			name: "typed fields",
+			graph: `{
+				"id": "g", "name": "Graph", "version": "1.0", "entry_node": "ask",
+				"nodes": {
+					"ask": {"id": "ask", "type": "executor", "executor_type": "llm",
+						"config": {"model": "gpt-4o", "prompt": "Answer", "temperature": 0.2, "max_tokens": 100,
+							"tools": [{"name": "web_search"}], "timeout": 30},
+						"input_mapping": {"question": "$.question"}, "output_mapping": {"answer": "$.answer"}},
+					"route": {"id": "route", "type": "router", "condition_type": "simple",
+						"routes": [{"condition": "$.ok", "target": "send", "priority": 1}], "default_route": "ask"},
+					"send": {"id": "send", "type": "executor", "executor_type": "tool",
+						"config": {"tool_name": "send_email", "parameters": {"to": "$.email"}}}
+				},
+				"edges": [{"id": "e1", "from": "ask", "to": "route", "label": "answered"}],
+				"metadata": {"author": "planner"}
+			}`,
+		},
+		{
+			name: "unknown fields at every level",
+			graph: `{
+				"id": "g", "entry_node": "a", "x_graph": [1, 2],
+				"nodes": {
+					"a": {"id": "a", "type": "executor", "executor_type": "custom", "x_node": {"k": "v"},
+						"config": {"handler": "pkg.Run", "retries": 3}},
+					"r": {"id": "r", "type": "router", "routes": [{"target": "a", "x_route": true}]}
+				},
+				"edges": [{"from": "a", "to": "r", "weight": 0.5}]
+			}`,
+		},
+		{
+			name: "loosely typed executor config",
+			graph: `{
+				"id": "g", "entry_node": "a",
+				"nodes": {
+					"a": {"id": "a", "type": "executor", "executor_type": "llm",
+						"config": {"prompt": "Search", "tools": ["web_search"], "timeout": "30s", "temperature": "low"}},
+					"b": {"id": "b", "type": "executor", "executor_type": "tool",
+						"config": {"tool_name": "lookup", "parameters": ["a"]}},
+					"c": {"id": "c", "type": "executor", "executor_type": "http",
+						"config": {"url": "https://example.com", "headers": ["Accept: application/json"], "body": "raw"}}
+				},
+				"edges": [{"from": "a", "to": "b"}, {"from": "b", "to": "c"}]
+			}`,
+		},
+		{
+			name: "loosely typed graph fields",
+			graph: `{
+				"id": "g", "entry_node": "r", "version": 2, "metadata": "none",
+				"nodes": {
+					"r": {"id": "r", "type": "router", "input_mapping": {"x": 1},
+						"routes": [{"target": "a", "priority": 1.5}, {"target": "b", "priority": "high"}]},
+					"a": {"id": "a", "type": "executor", "config": "free text"},
+					"b": {"id": 5, "type": "executor", "metadata": ["x"]}
+				},
+				"edges": [{"from": "r", "to": ["a"]}, {"from": "r", "to": "b"}]
+			}`,
+		},
+		{
+			name:  "nodes of the wrong type",
+			graph: `{"id": "g", "entry_node": "a", "nodes": "a"}`,
+		},
+		{
+			name:  "node array with a value that is not a node",
+			graph: `{"id": "g", "entry_node": "a", "nodes": [{"id": "a", "type": "executor"}, 1]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := ParseGraph([]byte(tt.graph))
+			if err != nil {
+				t.Fatalf("ParseGraph() error = %v", err)
+			}
+
+			data, err := json.Marshal(graph)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			assertSameJSON(t, data, tt.graph)
+
+			// A second round trip is stable
+			again, err := ParseGraph(data)
+			if err != nil {
+				t.Fatalf("ParseGraph() of the marshalled graph error = %v", err)
+			}
+			againData, err := json.Marshal(again)
+			if err != nil {
+				t.Fatalf("json.Marshal() of the parsed graph error = %v", err)
+			}
+			if string(againData) != string(data) {
+				t.Errorf("second round trip = %s\nwant %s", againData, data)
+			}
+		})
+	}
+}
+
+func TestParseGraphTypedFields(t *testing.T) {
+	graph, err := ParseGraph([]byte(`{
+		"id": "g", "entry_node": "a",
+		"nodes": {
+			"a": {"id": "a", "type": "executor", "executor_type": "llm",
+				"config": {"prompt": "Search", "tools": ["web_search"], "timeout": "30s", "max_tokens": 50}},
+			"b": {"id": "b", "type": "executor", "executor_type": "tool",
+				"config": {"tool_name": "lookup", "parameters": ["a"]}},
+			"r": {"id": "r", "type": "router", "routes": [{"target": "a", "priority": 1.5}], "default_route": "b"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseGraph() error = %v", err)
+	}
+
+	a := graph.Node("a").Config
+	if a.Prompt != "Search" || a.MaxTokens != 50 {
+		t.Errorf("config of a = %+v, want the fields that fit decoded", a)
+	}
+	if a.Tools != nil || a.Timeout != 0 {
+		t.Errorf("config of a has tools %v, timeout %d, want them left unset", a.Tools, a.Timeout)
+	}
+	if string(a.Extra["tools"]) != `["web_search"]` || string(a.Extra["timeout"]) != `"30s"` {
+		t.Errorf("Extra of a = %v, want the tools and timeout as given", a.Extra)
+	}
+
+	b := graph.Node("b")
+	if b.ToolName() != "lookup" || b.Config.Parameters != nil || string(b.Config.Extra["parameters"]) != `["a"]` {
+		t.Errorf("config of b = %+v, want the tool name decoded and the parameters in Extra", b.Config)
+	}
+
+	route := graph.Node("r").Routes[0]
+	if route.Priority != 0 || string(route.Extra["priority"]) != "1.5" {
+		t.Errorf("route = %+v, want the priority left unset and kept in Extra", route)
+	}
+
+	if targets := graph.Node("r").Targets(); !slices.Equal(targets, []string{"a", "b"}) {
+		t.Errorf("Targets() = %v, want [a b]", targets)
+	}
+}
+
+func TestParseGraphNodeArray(t *testing.T) {
+	graph, err := ParseGraph([]byte(`{
+		"id": "g", "entry_node": "a",
+		"nodes": [{"id": "a", "type": "executor"}, {"id": "b", "type": "executor"}],
+		"edges": [{"from": "a", "to": "b"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseGraph() error = %v", err)
+	}
+
+	if ids := graph.NodeIDs(); !slices.Equal(ids, []string{"a", "b"}) {
+		t.Errorf("NodeIDs() = %v, want [a b]", ids)
+	}
+
+	data, err := json.Marshal(graph)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	assertSameJSON(t, data, `{
+		"id": "g", "entry_node": "a",
+		"nodes": {"a": {"id": "a", "type": "executor"}, "b": {"id": "b", "type": "executor"}},
+		"edges": [{"from": "a", "to": "b"}]
+	}`)
+}
+
+func TestParseGraphErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph string
+	}{
+		{name: "not an object", graph: `["a"]`},
+		{name: "invalid JSON", graph: `{"id": "g",`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseGraph([]byte(tt.graph)); err == nil {
+				t.Error("ParseGraph() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestGraphTraversal(t *testing.T) {
+	graph, err := ParseGraph([]byte(`{
+		"id": "g", "entry_node": "start",
+		"nodes": {
+			"start": {"id": "start", "type": "executor"},
+			"route": {"id": "route", "type": "router", "routes": [{"target": "yes"}, {"target": "no"}], "default_route": "no"},
+			"yes": {"id": "yes", "type": "executor"},
+			"no": {"id": "no", "type": "executor"},
+			"orphan": {"id": "orphan", "type": "executor"}
+		},
+		"edges": [{"from": "start", "to": "route"}, {"from": "route", "to": "yes"}, {"from": "orphan", "to": "no"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseGraph() error = %v", err)
+	}
+
+	if got := graph.Successors("route"); !slices.Equal(got, []string{"yes", "no"}) {
+		t.Errorf("Successors(route) = %v, want [yes no]", got)
+	}
+	if got := graph.Predecessors("no"); !slices.Equal(got, []string{"orphan", "route"}) {
+		t.Errorf("Predecessors(no) = %v, want [orphan route]", got)
+	}
+
+	reached := graph.Reachable()
+	for _, id := range []string{"start", "route", "yes", "no"} {
+		if !reached[id] {
+			t.Errorf("Reachable() misses %s", id)
+		}
+	}
+	if reached["orphan"] {
+		t.Error("Reachable() includes orphan")
+	}
+
+	if graph.Node("missing") != nil || graph.Node("missing").Targets() != nil {
+		t.Error("Node(missing) is not nil")
+	}
+	if !graph.Node("route").IsRouter() || !graph.Node("yes").IsExecutor() {
+		t.Error("node types are not reported")
+	}
+}
@@ -23,7 +23,7 @@ type PlanResponse struct {
 	PlanID string `json:"plan_id"`
 
 	// Graph is the generated graph definition
-	Graph any `json:"graph"` // Using any to avoid circular dependency with dago-libs
+	Graph *Graph `json:"graph"`
 
 	// GraphJSON is the raw JSON representation of the graph
 	GraphJSON string `json:"graph_json,omitempty"`
@@ -40,6 +40,17 @@ type PlanResponse struct {
 	// ValidationLogs contains validation messages from each iteration
 	ValidationLogs []string `json:"validation_logs,omitempty"`
 
+	// Warnings lists the lint warnings of the graph, prefixed with their rule IDs
+	Warnings []string `json:"warnings,omitempty"`
+
+	// IterationReports describes each refinement iteration and the iteration
+	// strategies applied to it
+	IterationReports []IterationReport `json:"iteration_reports,omitempty"`
+
+	// Alternatives summarizes the candidate graphs that were not selected
+	// when several were sampled
+	Alternatives []CandidateSummary `json:"alternatives,omitempty"`
+
 	// Metadata contains metadata about the planning process
 	Metadata *PlanMetadata `json:"metadata"`
 
@@ -58,9 +69,38 @@ type PlanMetadata struct {
 	// TokensUsed is the total tokens consumed
 	TokensUsed int `json:"tokens_used"`
 
+	// InputTokens is the number of prompt tokens consumed
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the number of generated tokens
+	OutputTokens int `json:"output_tokens"`
+
+	// LLMCalls is the number of LLM calls made for this plan
+	LLMCalls int `json:"llm_calls"`
+
+	// Continuations is the number of LLM calls made to continue responses
+	// truncated at the output token limit (included in LLMCalls)
+	Continuations int `json:"continuations,omitempty"`
+
+	// EstimatedCost is the estimated cost of the plan in USD, based on the
+	// configured model pricing (0 if no pricing is configured)
+	EstimatedCost float64 `json:"estimated_cost"`
+
+	// PhaseUsage breaks token usage down by planning phase and iteration
+	PhaseUsage []PhaseUsage `json:"phase_usage,omitempty"`
+
 	// Duration is the total planning duration
 	Duration time.Duration `json:"duration"`
 
+	// Samples is the number of candidate graphs generated (0 without sampling)
+	Samples int `json:"samples,omitempty"`
+
+	// SampleScore is the score of the selected candidate when sampling
+	SampleScore float64 `json:"sample_score,omitempty"`
+
+	// Route records the complexity-based routing of the plan, if configured
+	Route *RouteDecision `json:"route,omitempty"`
+
 	// ConfidenceScore is an optional confidence score (0.0-1.0)
 	ConfidenceScore float64 `json:"confidence_score,omitempty"`
 
@@ -71,6 +111,57 @@ type PlanMetadata struct {
 	ErrorMessage string `json:"error_message,omitempty"`
 }
 
+// PhaseUsage contains the token usage of one planning phase.
+type PhaseUsage struct {
+	// Phase is the planning phase (e.g., "analysis", "generation")
+	Phase string `json:"phase"`
+
+	// Iteration is the refinement iteration, or 0 for single-shot phases
+	Iteration int `json:"iteration,omitempty"`
+
+	// Calls is the number of LLM calls made
+	Calls int `json:"calls"`
+
+	// FailedCalls is the number of calls that failed
+	FailedCalls int `json:"failed_calls,omitempty"`
+
+	// InputTokens is the number of prompt tokens consumed
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the number of generated tokens
+	OutputTokens int `json:"output_tokens"`
+
+	// TotalTokens is the total tokens consumed
+	TotalTokens int `json:"total_tokens"`
+
+	// Cost is the estimated cost in USD
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// UsageStats contains aggregate LLM usage since the service started.
+type UsageStats struct {
+	// TotalCalls is the number of LLM calls made
+	TotalCalls int `json:"total_calls"`
+
+	// SuccessfulCalls is the number of successful calls
+	SuccessfulCalls int `json:"successful_calls"`
+
+	// FailedCalls is the number of failed calls
+	FailedCalls int `json:"failed_calls"`
+
+	// InputTokens is the total prompt tokens consumed
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the total generated tokens
+	OutputTokens int `json:"output_tokens"`
+
+	// TotalTokens is the total tokens consumed
+	TotalTokens int `json:"total_tokens"`
+
+	// TotalCost is the total estimated cost in USD
+	TotalCost float64 `json:"total_cost"`
+}
+
 // ValidationResult represents the result of graph validation.
 type ValidationResult struct {
 	// Valid indicates if the graph is valid
@@ -112,3 +203,79 @@ type IterationLog struct {
 	// Timestamp is when this iteration occurred
 	Timestamp time.Time `json:"timestamp"`
 }
+
+// CandidateSummary summarizes a candidate graph generated by self-consistency
+// sampling.
+type CandidateSummary struct {
+	// Candidate is the 1-based index of the candidate
+	Candidate int `json:"candidate"`
+
+	// Valid indicates if the candidate produced a graph that passed validation
+	Valid bool `json:"valid"`
+
+	// Score ranks valid candidates; the highest scoring one is selected
+	Score float64 `json:"score,omitempty"`
+
+	// NodeCount is the number of nodes of the candidate graph
+	NodeCount int `json:"node_count,omitempty"`
+
+	// Iterations is the number of refinement iterations the candidate needed
+	Iterations int `json:"iterations,omitempty"`
+
+	// Notes explains the deductions from the candidate's score
+	Notes []string `json:"notes,omitempty"`
+
+	// Reasoning is the LLM's reasoning for the candidate graph
+	Reasoning string `json:"reasoning,omitempty"`
+
+	// Error explains why the candidate failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// IterationReport describes one refinement iteration of graph generation.
+type IterationReport struct {
+	// Iteration is the 1-based iteration number
+	Iteration int `json:"iteration"`
+
+	// Phase is the planning phase of the iteration ("generation" or "repair")
+	Phase string `json:"phase"`
+
+	// Model is the model requested for the iteration (empty for the default)
+	Model string `json:"model,omitempty"`
+
+	// Temperature is the sampling temperature of the iteration
+	Temperature float64 `json:"temperature"`
+
+	// Restarted indicates the iteration started over from the planning prompt
+	Restarted bool `json:"restarted,omitempty"`
+
+	// Actions lists the iteration strategies applied (e.g., "escalate:gpt-4o")
+	Actions []string `json:"actions,omitempty"`
+
+	// Error explains why the iteration failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// RouteDecision records the model and limits a plan was routed to by task
+// complexity.
+type RouteDecision struct {
+	// Complexity is the analyzed task complexity (empty if the task was not analyzed)
+	Complexity ComplexityLevel `json:"complexity,omitempty"`
+
+	// Route is the routing table entry applied, or "default" if none matched
+	Route string `json:"route"`
+
+	// Model is the model of generation and repair calls (empty if the
+	// route keeps the models of the phase profiles)
+	Model string `json:"model,omitempty"`
+
+	// MaxIterations is the refinement iteration budget
+	MaxIterations int `json:"max_iterations"`
+
+	// MaxTokens is the output token limit of each generation and repair
+	// call (0 if the route keeps the limits of the phase profiles)
+	MaxTokens int `json:"max_tokens,omitempty"`
+
+	// MaxTokensBudget is the total token budget of the plan (0 if unlimited)
+	MaxTokensBudget int `json:"max_tokens_budget,omitempty"`
+}
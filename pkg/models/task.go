@@ -37,6 +37,21 @@ type Constraints struct {
 
 	// RequireValidation ensures the graph is validated before returning
 	RequireValidation bool `json:"require_validation,omitempty"`
+
+	// MaxTokensBudget caps the total LLM tokens (input and output) spent on the plan
+	MaxTokensBudget int `json:"max_tokens_budget,omitempty"`
+
+	// MaxCost caps the estimated LLM cost of the plan in USD
+	// (requires model pricing to be configured)
+	MaxCost float64 `json:"max_cost,omitempty"`
+
+	// Samples is the number of candidate graphs to generate concurrently;
+	// the best valid one is returned (overrides the service default)
+	Samples int `json:"samples,omitempty"`
+
+	// SampleTemperature is the temperature of candidate generation
+	// (overrides the service default)
+	SampleTemperature float64 `json:"sample_temperature,omitempty"`
 }
 
 // TaskAnalysis contains the results of task analysis.